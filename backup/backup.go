@@ -0,0 +1,440 @@
+// Package backup exports a whole box, including its records and automations,
+// into a single archive and restores boxes from such archives.
+//
+// An archive is a gzip compressed stream of json lines. The first line is a
+// Header holding the format version and the box, every following line is an
+// Entry holding either an automation, an automation event, a record, an
+// edge between records or the source of a record. Entries are written in this order, so that entries
+// are always written after the ones they refer to and both export and import
+// can stream the archive without holding it in memory.
+package backup
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hntr/db"
+	"hntr/search"
+	"io"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// Version of the archive format written by Export.
+const Version = 1
+
+var (
+	ErrInvalidArchive = errors.New("invalid archive")
+	ErrBoxExists      = errors.New("box already exists")
+	ErrTooManyRecords = errors.New("too many records")
+)
+
+// Header is the first line of an archive.
+type Header struct {
+	Version int    `json:"version"`
+	Box     db.Box `json:"box"`
+}
+
+// Entry is a single line following the header. Exactly one field is set.
+type Entry struct {
+	Automation *db.Automation      `json:"automation,omitempty"`
+	Event      *db.AutomationEvent `json:"event,omitempty"`
+	Record     *db.Record          `json:"record,omitempty"`
+	Edge       *db.RecordEdge      `json:"edge,omitempty"`
+	Source     *db.RecordSource    `json:"source,omitempty"`
+}
+
+type ExportOptions struct {
+	// Events includes the automation events of the box.
+	Events bool
+}
+
+// Export writes the box with the given id as archive to w.
+func Export(ctx context.Context, repo *db.Queries, boxID uuid.UUID, w io.Writer, opts ExportOptions) error {
+	box, err := repo.GetBox(ctx, boxID)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	enc := json.NewEncoder(gz)
+
+	if err := enc.Encode(Header{Version: Version, Box: box}); err != nil {
+		return err
+	}
+
+	automations, err := repo.ListAutomations(ctx, box.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range automations {
+		if err := enc.Encode(Entry{Automation: &automations[i]}); err != nil {
+			return err
+		}
+	}
+
+	if opts.Events {
+		events, err := repo.ListAutomationEventsByBox(ctx, box.ID)
+		if err != nil {
+			return err
+		}
+
+		for i := range events {
+			if err := enc.Encode(Entry{Event: &events[i]}); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, container := range box.Containers {
+		params := db.ListRecordsBySearchParams{
+			BoxID:     box.ID,
+			Container: container,
+			Query:     search.MatchTags(nil),
+		}
+
+		err := repo.StreamRecordsBySearch(ctx, params, func(record db.Record) error {
+			return enc.Encode(Entry{Record: &record})
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	edges, err := repo.ListRecordEdgesByBox(ctx, box.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range edges {
+		if err := enc.Encode(Entry{Edge: &edges[i]}); err != nil {
+			return err
+		}
+	}
+
+	sources, err := repo.ListRecordSourcesByBox(ctx, box.ID)
+	if err != nil {
+		return err
+	}
+
+	for i := range sources {
+		if err := enc.Encode(Entry{Source: &sources[i]}); err != nil {
+			return err
+		}
+	}
+
+	return gz.Close()
+}
+
+type ImportOptions struct {
+	// KeepID restores the box, its automations and events under their
+	// original ids. Import fails with ErrBoxExists if the box id is taken.
+	// Otherwise new ids are assigned.
+	KeepID bool
+
+	// RecordsLimit fails the import if the archive holds more records. A
+	// limit of zero imports all records.
+	RecordsLimit int
+}
+
+// Import restores a box from an archive written by Export. The import runs
+// in a single transaction and either restores everything or nothing.
+func Import(ctx context.Context, dbPool *pgxpool.Pool, r io.Reader, opts ImportOptions) (db.Box, error) {
+	var box db.Box
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return box, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	defer gz.Close()
+
+	dec := json.NewDecoder(bufio.NewReader(gz))
+
+	header := Header{}
+	if err := dec.Decode(&header); err != nil {
+		return box, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	if header.Version != Version {
+		return box, fmt.Errorf("%w: unsupported version %d", ErrInvalidArchive, header.Version)
+	}
+
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return box, err
+	}
+	defer tx.Rollback(ctx)
+
+	repo := db.New(dbPool).WithTx(tx)
+
+	id := header.Box.ID
+	if !opts.KeepID {
+		id = uuid.New()
+	} else if _, err := repo.GetBox(ctx, id); err == nil {
+		return box, ErrBoxExists
+	} else if err != pgx.ErrNoRows {
+		return box, err
+	}
+
+	settings := presentJSONB(header.Box.ContainerSettings)
+	boxScope := presentJSONB(header.Box.Scope)
+
+	box, err = repo.RestoreBox(ctx, db.RestoreBoxParams{
+		ID:                id,
+		Name:              header.Box.Name,
+		Containers:        header.Box.Containers,
+		CreatedAt:         header.Box.CreatedAt,
+		ContainerSettings: settings,
+		Scope:             boxScope,
+	})
+	if err != nil {
+		return box, err
+	}
+
+	automations := make(map[uuid.UUID]uuid.UUID)
+	events := make(map[uuid.UUID]uuid.UUID)
+
+	// automations and events precede the records and are restored before
+	// the records are streamed into COPY, as no other query can be sent on
+	// the connection while COPY is in progress
+	var first *db.Record
+	for first == nil {
+		entry := Entry{}
+		if err := dec.Decode(&entry); err == io.EOF {
+			break
+		} else if err != nil {
+			return box, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+		}
+
+		switch {
+		case entry.Automation != nil:
+			a := entry.Automation
+			automations[a.ID] = newID(a.ID, opts.KeepID)
+
+			err = repo.RestoreAutomation(ctx, db.RestoreAutomationParams{
+				ID:                   automations[a.ID],
+				Name:                 a.Name,
+				Description:          a.Description,
+				BoxID:                box.ID,
+				Command:              a.Command,
+				SourceContainer:      a.SourceContainer,
+				SourceTags:           a.SourceTags,
+				SourceTerm:           a.SourceTerm,
+				DestinationContainer: a.DestinationContainer,
+				DestinationTags:      a.DestinationTags,
+				IsPublic:             a.IsPublic,
+				CreatedAt:            a.CreatedAt,
+			})
+		case entry.Event != nil:
+			ev := entry.Event
+			automationID, ok := automations[ev.AutomationID]
+			if !ok {
+				return box, fmt.Errorf("%w: event %v of unknown automation", ErrInvalidArchive, ev.ID)
+			}
+
+			events[ev.ID] = newID(ev.ID, opts.KeepID)
+
+			err = repo.RestoreAutomationEvent(ctx, db.RestoreAutomationEventParams{
+				ID:           events[ev.ID],
+				BoxID:        box.ID,
+				AutomationID: automationID,
+				Status:       ev.Status,
+				Data:         ev.Data,
+				AffectedRows: ev.AffectedRows,
+				CreatedAt:    ev.CreatedAt,
+				StartedAt:    ev.StartedAt,
+				FinishedAt:   ev.FinishedAt,
+				Report:       nullJSONB(ev.Report),
+			})
+		case entry.Record != nil:
+			first = entry.Record
+		case entry.Edge != nil, entry.Source != nil:
+			return box, fmt.Errorf("%w: edge or source before records", ErrInvalidArchive)
+		}
+
+		if err != nil {
+			return box, err
+		}
+	}
+
+	if first != nil {
+		records := &recordReader{
+			dec:   dec,
+			boxID: box.ID,
+			next:  first,
+			limit: opts.RecordsLimit,
+		}
+
+		_, err = tx.CopyFrom(
+			ctx,
+			pgx.Identifier{"records"},
+			[]string{"data", "tags", "box_id", "container", "created_at", "attributes", "last_seen_at", "seen_count"},
+			records,
+		)
+		if records.err != nil {
+			// the error of the source is only passed to the server as text
+			return box, records.err
+		} else if err != nil {
+			return box, err
+		}
+
+		// edges and sources follow the records they refer to
+		for entry := records.rest; entry != nil; {
+			switch {
+			case entry.Edge != nil:
+				err = restoreEdge(ctx, repo, box.ID, entry.Edge, automations)
+			case entry.Source != nil:
+				err = restoreSource(ctx, repo, box.ID, entry.Source, automations, events, opts.KeepID)
+			default:
+				err = fmt.Errorf("%w: unexpected entry after records", ErrInvalidArchive)
+			}
+			if err != nil {
+				return box, err
+			}
+
+			entry = &Entry{}
+			if err := dec.Decode(entry); err == io.EOF {
+				break
+			} else if err != nil {
+				return box, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+			}
+		}
+	}
+
+	return box, tx.Commit(ctx)
+}
+
+func restoreEdge(ctx context.Context, repo *db.Queries, boxID uuid.UUID, edge *db.RecordEdge, automations map[uuid.UUID]uuid.UUID) error {
+	automationID := edge.AutomationID
+	automationID.UUID, automationID.Valid = automations[automationID.UUID]
+
+	return repo.RestoreRecordEdge(ctx, db.RestoreRecordEdgeParams{
+		BoxID:           boxID,
+		SourceContainer: edge.SourceContainer,
+		SourceData:      edge.SourceData,
+		TargetContainer: edge.TargetContainer,
+		TargetData:      edge.TargetData,
+		AutomationID:    automationID,
+		CreatedAt:       edge.CreatedAt,
+	})
+}
+
+// restoreSource maps the automation and event of a source to their restored
+// ids. Events missing in the archive keep their id only if the box keeps its
+// id, as the events are removed after a while anyway.
+func restoreSource(ctx context.Context, repo *db.Queries, boxID uuid.UUID, source *db.RecordSource, automations, events map[uuid.UUID]uuid.UUID, keepID bool) error {
+	automationID := source.AutomationID
+	automationID.UUID, automationID.Valid = automations[automationID.UUID]
+
+	eventID := source.EventID
+	if id, ok := events[eventID.UUID]; ok {
+		eventID.UUID = id
+	} else if !keepID {
+		eventID = uuid.NullUUID{}
+	}
+
+	return repo.RestoreRecordSource(ctx, db.RestoreRecordSourceParams{
+		BoxID:        boxID,
+		Container:    source.Container,
+		Data:         source.Data,
+		Kind:         source.Kind,
+		EventID:      eventID,
+		AutomationID: automationID,
+		Command:      source.Command,
+		Input:        source.Input,
+		CreatedAt:    source.CreatedAt,
+	})
+}
+
+// presentJSONB returns an empty json object for columns missing in an archive.
+func presentJSONB(v pgtype.JSONB) pgtype.JSONB {
+	if v.Status != pgtype.Present {
+		return pgtype.JSONB{Bytes: []byte("{}"), Status: pgtype.Present}
+	}
+
+	return v
+}
+
+// nullJSONB returns null for nullable columns missing in an archive.
+func nullJSONB(v pgtype.JSONB) pgtype.JSONB {
+	if v.Status == pgtype.Undefined {
+		return pgtype.JSONB{Status: pgtype.Null}
+	}
+
+	return v
+}
+
+func newID(id uuid.UUID, keep bool) uuid.UUID {
+	if keep {
+		return id
+	}
+
+	return uuid.New()
+}
+
+// recordReader implements pgx.CopyFromSource for the records of an archive.
+// It stops at the first entry following the records, which is kept for the
+// caller.
+type recordReader struct {
+	dec   *json.Decoder
+	boxID uuid.UUID
+
+	next   *db.Record
+	record db.Record
+	rest   *Entry
+	count  int
+	limit  int
+	err    error
+}
+
+func (r *recordReader) Next() bool {
+	if r.next == nil {
+		entry := Entry{}
+		if err := r.dec.Decode(&entry); err == io.EOF {
+			return false
+		} else if err != nil {
+			r.err = fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+			return false
+		}
+
+		if entry.Record == nil {
+			r.rest = &entry
+			return false
+		}
+
+		r.next = entry.Record
+	}
+
+	r.count++
+	if r.limit > 0 && r.count > r.limit {
+		r.err = fmt.Errorf("%w: limit is %d", ErrTooManyRecords, r.limit)
+		return false
+	}
+
+	r.record, r.next = *r.next, nil
+	return true
+}
+
+func (r *recordReader) Values() ([]interface{}, error) {
+	return []interface{}{
+		r.record.Data,
+		r.record.Tags,
+		r.boxID,
+		r.record.Container,
+		r.record.CreatedAt,
+		r.record.Attributes,
+		r.record.LastSeenAt,
+		r.record.SeenCount,
+	}, nil
+}
+
+func (r *recordReader) Err() error {
+	return r.err
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"hntr/backup"
+	"hntr/db"
+	"log"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+func backupDb(repo *db.Queries, boxID string, events bool) error {
+	id, err := uuid.Parse(boxID)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(os.Stdout)
+	if err := backup.Export(context.Background(), repo, id, w, backup.ExportOptions{Events: events}); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+func restoreDb(dbPool *pgxpool.Pool, file string, keepID bool) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	box, err := backup.Import(context.Background(), dbPool, bufio.NewReader(f), backup.ImportOptions{KeepID: keepID})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("restored box %v", box.ID)
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"hntr/db"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// updateQuota sets the quotas of a box. Values of 0 or less remove the quota,
+// so the instance wide limits apply again.
+func updateQuota(repo *db.Queries, boxID string, records, events int) error {
+	id, err := uuid.Parse(boxID)
+	if err != nil {
+		return err
+	}
+
+	quota := func(n int) sql.NullInt64 {
+		return sql.NullInt64{Int64: int64(n), Valid: n > 0}
+	}
+
+	if err := repo.UpdateBoxQuota(context.Background(), db.UpdateBoxQuotaParams{
+		RecordsQuota: quota(records),
+		EventsQuota:  quota(events),
+		ID:           id,
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("updated quotas of box %v", id)
+	return nil
+}
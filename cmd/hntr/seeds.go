@@ -24,7 +24,7 @@ func seedDb(repo *db.Queries) error {
 			BoxID:                box.ID,
 			Command:              "amass enum -passive -d {data}",
 			SourceContainer:      "hostnames",
-			SourceTags:           []string{"is_scope"},
+			SourceTags:           []string{"in_scope"},
 			DestinationContainer: "hostnames",
 			DestinationTags:      []string{"source:amass"},
 			IsPublic:             true,
@@ -35,7 +35,7 @@ func seedDb(repo *db.Queries) error {
 			BoxID:                box.ID,
 			Command:              "echo {data} | subfinder",
 			SourceContainer:      "hostnames",
-			SourceTags:           []string{"is_scope"},
+			SourceTags:           []string{"in_scope"},
 			DestinationContainer: "hostnames",
 			DestinationTags:      []string{"source:subfinder"},
 			IsPublic:             true,
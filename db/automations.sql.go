@@ -5,8 +5,11 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
 )
 
 const countAutomationEvents = `-- name: CountAutomationEvents :one
@@ -22,8 +25,8 @@ func (q *Queries) CountAutomationEvents(ctx context.Context, boxID uuid.UUID) (i
 
 const createAutomation = `-- name: CreateAutomation :one
 INSERT INTO automations (
-    name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id, name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public, created_at
+    name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public, source_term
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public, created_at, source_term
 `
 
 type CreateAutomationParams struct {
@@ -36,6 +39,7 @@ type CreateAutomationParams struct {
 	DestinationContainer string    `json:"destination_container"`
 	DestinationTags      []string  `json:"destination_tags"`
 	IsPublic             bool      `json:"is_public"`
+	SourceTerm           string    `json:"source_term"`
 }
 
 func (q *Queries) CreateAutomation(ctx context.Context, arg CreateAutomationParams) (Automation, error) {
@@ -49,6 +53,7 @@ func (q *Queries) CreateAutomation(ctx context.Context, arg CreateAutomationPara
 		arg.DestinationContainer,
 		arg.DestinationTags,
 		arg.IsPublic,
+		arg.SourceTerm,
 	)
 	var i Automation
 	err := row.Scan(
@@ -63,6 +68,7 @@ func (q *Queries) CreateAutomation(ctx context.Context, arg CreateAutomationPara
 		&i.DestinationTags,
 		&i.IsPublic,
 		&i.CreatedAt,
+		&i.SourceTerm,
 	)
 	return i, err
 }
@@ -70,7 +76,7 @@ func (q *Queries) CreateAutomation(ctx context.Context, arg CreateAutomationPara
 const createAutomationEvent = `-- name: CreateAutomationEvent :one
 INSERT INTO automation_events (
     box_id, automation_id, data, status, affected_rows
-) VALUES ($1, $2, $3, $4, $5) RETURNING id, box_id, automation_id, status, data, affected_rows, created_at, started_at, finished_at
+) VALUES ($1, $2, $3, $4, $5) RETURNING id, box_id, automation_id, status, data, affected_rows, created_at, started_at, finished_at, report
 `
 
 type CreateAutomationEventParams struct {
@@ -100,10 +106,31 @@ func (q *Queries) CreateAutomationEvent(ctx context.Context, arg CreateAutomatio
 		&i.CreatedAt,
 		&i.StartedAt,
 		&i.FinishedAt,
+		&i.Report,
 	)
 	return i, err
 }
 
+const createAutomationEvents = `-- name: CreateAutomationEvents :execrows
+INSERT INTO automation_events (
+    box_id, automation_id, data, status
+) SELECT $1::uuid, $2::uuid, unnest($3::text[]), 'scheduled'
+`
+
+type CreateAutomationEventsParams struct {
+	BoxID        uuid.UUID `json:"box_id"`
+	AutomationID uuid.UUID `json:"automation_id"`
+	Data         []string  `json:"data"`
+}
+
+func (q *Queries) CreateAutomationEvents(ctx context.Context, arg CreateAutomationEventsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, createAutomationEvents, arg.BoxID, arg.AutomationID, arg.Data)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deleteAutomation = `-- name: DeleteAutomation :exec
 DELETE FROM automations WHERE id = $1
 `
@@ -146,7 +173,7 @@ WHERE id IN (
     ORDER BY ae.created_at
     FOR UPDATE SKIP LOCKED
     LIMIT $2
-) RETURNING id, box_id, automation_id, status, data, affected_rows, created_at, started_at, finished_at
+) RETURNING id, box_id, automation_id, status, data, affected_rows, created_at, started_at, finished_at, report
 `
 
 type DequeueAutomationEventsParams struct {
@@ -173,6 +200,7 @@ func (q *Queries) DequeueAutomationEvents(ctx context.Context, arg DequeueAutoma
 			&i.CreatedAt,
 			&i.StartedAt,
 			&i.FinishedAt,
+			&i.Report,
 		); err != nil {
 			return nil, err
 		}
@@ -185,7 +213,7 @@ func (q *Queries) DequeueAutomationEvents(ctx context.Context, arg DequeueAutoma
 }
 
 const getAutomation = `-- name: GetAutomation :one
-SELECT id, name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public, created_at FROM automations WHERE id = $1 LIMIT 1
+SELECT id, name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public, created_at, source_term FROM automations WHERE id = $1 LIMIT 1
 `
 
 func (q *Queries) GetAutomation(ctx context.Context, id uuid.UUID) (Automation, error) {
@@ -203,12 +231,13 @@ func (q *Queries) GetAutomation(ctx context.Context, id uuid.UUID) (Automation,
 		&i.DestinationTags,
 		&i.IsPublic,
 		&i.CreatedAt,
+		&i.SourceTerm,
 	)
 	return i, err
 }
 
 const getAutomationEvent = `-- name: GetAutomationEvent :one
-SELECT id, box_id, automation_id, status, data, affected_rows, created_at, started_at, finished_at FROM automation_events WHERE id = $1 LIMIT 1
+SELECT id, box_id, automation_id, status, data, affected_rows, created_at, started_at, finished_at, report FROM automation_events WHERE id = $1 LIMIT 1
 `
 
 func (q *Queries) GetAutomationEvent(ctx context.Context, id uuid.UUID) (AutomationEvent, error) {
@@ -224,6 +253,7 @@ func (q *Queries) GetAutomationEvent(ctx context.Context, id uuid.UUID) (Automat
 		&i.CreatedAt,
 		&i.StartedAt,
 		&i.FinishedAt,
+		&i.Report,
 	)
 	return i, err
 }
@@ -258,7 +288,7 @@ func (q *Queries) GetAutomationEventCounts(ctx context.Context, boxID uuid.UUID)
 }
 
 const listAutomationEvents = `-- name: ListAutomationEvents :many
-SELECT id, box_id, automation_id, status, data, affected_rows, created_at, started_at, finished_at FROM automation_events WHERE automation_id = $1 ORDER BY created_at DESC LIMIT $2
+SELECT id, box_id, automation_id, status, data, affected_rows, created_at, started_at, finished_at, report FROM automation_events WHERE automation_id = $1 ORDER BY created_at DESC LIMIT $2
 `
 
 type ListAutomationEventsParams struct {
@@ -285,6 +315,42 @@ func (q *Queries) ListAutomationEvents(ctx context.Context, arg ListAutomationEv
 			&i.CreatedAt,
 			&i.StartedAt,
 			&i.FinishedAt,
+			&i.Report,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listAutomationEventsByBox = `-- name: ListAutomationEventsByBox :many
+SELECT id, box_id, automation_id, status, data, affected_rows, created_at, started_at, finished_at, report FROM automation_events WHERE box_id = $1 ORDER BY created_at
+`
+
+func (q *Queries) ListAutomationEventsByBox(ctx context.Context, boxID uuid.UUID) ([]AutomationEvent, error) {
+	rows, err := q.db.Query(ctx, listAutomationEventsByBox, boxID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AutomationEvent{}
+	for rows.Next() {
+		var i AutomationEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.BoxID,
+			&i.AutomationID,
+			&i.Status,
+			&i.Data,
+			&i.AffectedRows,
+			&i.CreatedAt,
+			&i.StartedAt,
+			&i.FinishedAt,
+			&i.Report,
 		); err != nil {
 			return nil, err
 		}
@@ -298,7 +364,7 @@ func (q *Queries) ListAutomationEvents(ctx context.Context, arg ListAutomationEv
 
 const listAutomationLibrary = `-- name: ListAutomationLibrary :many
 SELECT 
-    id, name, description, command, source_container, source_tags, destination_container, destination_tags, is_public
+    id, name, description, command, source_container, source_tags, destination_container, destination_tags, is_public, source_term
 FROM automations
 WHERE is_public = true
 `
@@ -313,6 +379,7 @@ type ListAutomationLibraryRow struct {
 	DestinationContainer string    `json:"destination_container"`
 	DestinationTags      []string  `json:"destination_tags"`
 	IsPublic             bool      `json:"is_public"`
+	SourceTerm           string    `json:"source_term"`
 }
 
 func (q *Queries) ListAutomationLibrary(ctx context.Context) ([]ListAutomationLibraryRow, error) {
@@ -334,6 +401,7 @@ func (q *Queries) ListAutomationLibrary(ctx context.Context) ([]ListAutomationLi
 			&i.DestinationContainer,
 			&i.DestinationTags,
 			&i.IsPublic,
+			&i.SourceTerm,
 		); err != nil {
 			return nil, err
 		}
@@ -346,7 +414,7 @@ func (q *Queries) ListAutomationLibrary(ctx context.Context) ([]ListAutomationLi
 }
 
 const listAutomations = `-- name: ListAutomations :many
-SELECT id, name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public, created_at FROM automations WHERE box_id = $1
+SELECT id, name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public, created_at, source_term FROM automations WHERE box_id = $1
 `
 
 func (q *Queries) ListAutomations(ctx context.Context, boxID uuid.UUID) ([]Automation, error) {
@@ -370,6 +438,7 @@ func (q *Queries) ListAutomations(ctx context.Context, boxID uuid.UUID) ([]Autom
 			&i.DestinationTags,
 			&i.IsPublic,
 			&i.CreatedAt,
+			&i.SourceTerm,
 		); err != nil {
 			return nil, err
 		}
@@ -381,6 +450,80 @@ func (q *Queries) ListAutomations(ctx context.Context, boxID uuid.UUID) ([]Autom
 	return items, nil
 }
 
+const restoreAutomation = `-- name: RestoreAutomation :exec
+INSERT INTO automations (
+    id, name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public, created_at, source_term
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+`
+
+type RestoreAutomationParams struct {
+	ID                   uuid.UUID `json:"id"`
+	Name                 string    `json:"name"`
+	Description          string    `json:"description"`
+	BoxID                uuid.UUID `json:"box_id"`
+	Command              string    `json:"command"`
+	SourceContainer      string    `json:"source_container"`
+	SourceTags           []string  `json:"source_tags"`
+	DestinationContainer string    `json:"destination_container"`
+	DestinationTags      []string  `json:"destination_tags"`
+	IsPublic             bool      `json:"is_public"`
+	CreatedAt            time.Time `json:"created_at"`
+	SourceTerm           string    `json:"source_term"`
+}
+
+func (q *Queries) RestoreAutomation(ctx context.Context, arg RestoreAutomationParams) error {
+	_, err := q.db.Exec(ctx, restoreAutomation,
+		arg.ID,
+		arg.Name,
+		arg.Description,
+		arg.BoxID,
+		arg.Command,
+		arg.SourceContainer,
+		arg.SourceTags,
+		arg.DestinationContainer,
+		arg.DestinationTags,
+		arg.IsPublic,
+		arg.CreatedAt,
+		arg.SourceTerm,
+	)
+	return err
+}
+
+const restoreAutomationEvent = `-- name: RestoreAutomationEvent :exec
+INSERT INTO automation_events (
+    id, box_id, automation_id, status, data, affected_rows, created_at, started_at, finished_at, report
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+`
+
+type RestoreAutomationEventParams struct {
+	ID           uuid.UUID    `json:"id"`
+	BoxID        uuid.UUID    `json:"box_id"`
+	AutomationID uuid.UUID    `json:"automation_id"`
+	Status       string       `json:"status"`
+	Data         string       `json:"data"`
+	AffectedRows int32        `json:"affected_rows"`
+	CreatedAt    time.Time    `json:"created_at"`
+	StartedAt    sql.NullTime `json:"started_at"`
+	FinishedAt   sql.NullTime `json:"finished_at"`
+	Report       pgtype.JSONB `json:"report"`
+}
+
+func (q *Queries) RestoreAutomationEvent(ctx context.Context, arg RestoreAutomationEventParams) error {
+	_, err := q.db.Exec(ctx, restoreAutomationEvent,
+		arg.ID,
+		arg.BoxID,
+		arg.AutomationID,
+		arg.Status,
+		arg.Data,
+		arg.AffectedRows,
+		arg.CreatedAt,
+		arg.StartedAt,
+		arg.FinishedAt,
+		arg.Report,
+	)
+	return err
+}
+
 const updateAutomation = `-- name: UpdateAutomation :exec
 UPDATE automations SET
     name=$1,
@@ -389,8 +532,9 @@ UPDATE automations SET
     source_tags=$4,
     destination_container=$5,
     destination_tags=$6,
-    command=$7
-WHERE id = $8
+    command=$7,
+    source_term=$8
+WHERE id = $9
 `
 
 type UpdateAutomationParams struct {
@@ -401,6 +545,7 @@ type UpdateAutomationParams struct {
 	DestinationContainer string    `json:"destination_container"`
 	DestinationTags      []string  `json:"destination_tags"`
 	Command              string    `json:"command"`
+	SourceTerm           string    `json:"source_term"`
 	ID                   uuid.UUID `json:"id"`
 }
 
@@ -413,6 +558,7 @@ func (q *Queries) UpdateAutomation(ctx context.Context, arg UpdateAutomationPara
 		arg.DestinationContainer,
 		arg.DestinationTags,
 		arg.Command,
+		arg.SourceTerm,
 		arg.ID,
 	)
 	return err
@@ -433,16 +579,36 @@ func (q *Queries) UpdateAutomationEventStatus(ctx context.Context, arg UpdateAut
 }
 
 const updateAutomationEventStatusFinished = `-- name: UpdateAutomationEventStatusFinished :exec
-UPDATE automation_events SET status = $1, affected_rows = $2, finished_at = now() where id = $3
+UPDATE automation_events SET status = $1, affected_rows = $2, report = $3, finished_at = now() where id = $4
 `
 
 type UpdateAutomationEventStatusFinishedParams struct {
-	Status       string    `json:"status"`
-	AffectedRows int32     `json:"affected_rows"`
-	ID           uuid.UUID `json:"id"`
+	Status       string       `json:"status"`
+	AffectedRows int32        `json:"affected_rows"`
+	Report       pgtype.JSONB `json:"report"`
+	ID           uuid.UUID    `json:"id"`
 }
 
 func (q *Queries) UpdateAutomationEventStatusFinished(ctx context.Context, arg UpdateAutomationEventStatusFinishedParams) error {
-	_, err := q.db.Exec(ctx, updateAutomationEventStatusFinished, arg.Status, arg.AffectedRows, arg.ID)
+	_, err := q.db.Exec(ctx, updateAutomationEventStatusFinished,
+		arg.Status,
+		arg.AffectedRows,
+		arg.Report,
+		arg.ID,
+	)
+	return err
+}
+
+const updateAutomationSourceTerm = `-- name: UpdateAutomationSourceTerm :exec
+UPDATE automations SET source_term = $1 WHERE id = $2
+`
+
+type UpdateAutomationSourceTermParams struct {
+	SourceTerm string    `json:"source_term"`
+	ID         uuid.UUID `json:"id"`
+}
+
+func (q *Queries) UpdateAutomationSourceTerm(ctx context.Context, arg UpdateAutomationSourceTermParams) error {
+	_, err := q.db.Exec(ctx, updateAutomationSourceTerm, arg.SourceTerm, arg.ID)
 	return err
 }
@@ -5,12 +5,15 @@ package db
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
 )
 
 const createBox = `-- name: CreateBox :one
-INSERT INTO boxes (name, containers) VALUES ($1, $2) RETURNING id, name, containers, created_at, last_accessed_at
+INSERT INTO boxes (name, containers) VALUES ($1, $2) RETURNING id, name, containers, created_at, last_accessed_at, container_settings, scope, records_quota, events_quota
 `
 
 type CreateBoxParams struct {
@@ -27,6 +30,10 @@ func (q *Queries) CreateBox(ctx context.Context, arg CreateBoxParams) (Box, erro
 		&i.Containers,
 		&i.CreatedAt,
 		&i.LastAccessedAt,
+		&i.ContainerSettings,
+		&i.Scope,
+		&i.RecordsQuota,
+		&i.EventsQuota,
 	)
 	return i, err
 }
@@ -41,7 +48,7 @@ func (q *Queries) DeleteBox(ctx context.Context, id uuid.UUID) error {
 }
 
 const getBox = `-- name: GetBox :one
-SELECT id, name, containers, created_at, last_accessed_at FROM boxes WHERE id = $1 LIMIT 1
+SELECT id, name, containers, created_at, last_accessed_at, container_settings, scope, records_quota, events_quota FROM boxes WHERE id = $1 LIMIT 1
 `
 
 func (q *Queries) GetBox(ctx context.Context, id uuid.UUID) (Box, error) {
@@ -53,12 +60,16 @@ func (q *Queries) GetBox(ctx context.Context, id uuid.UUID) (Box, error) {
 		&i.Containers,
 		&i.CreatedAt,
 		&i.LastAccessedAt,
+		&i.ContainerSettings,
+		&i.Scope,
+		&i.RecordsQuota,
+		&i.EventsQuota,
 	)
 	return i, err
 }
 
 const listBoxes = `-- name: ListBoxes :many
-SELECT id, name, containers, created_at, last_accessed_at FROM boxes
+SELECT id, name, containers, created_at, last_accessed_at, container_settings, scope, records_quota, events_quota FROM boxes
 `
 
 func (q *Queries) ListBoxes(ctx context.Context) ([]Box, error) {
@@ -76,6 +87,10 @@ func (q *Queries) ListBoxes(ctx context.Context) ([]Box, error) {
 			&i.Containers,
 			&i.CreatedAt,
 			&i.LastAccessedAt,
+			&i.ContainerSettings,
+			&i.Scope,
+			&i.RecordsQuota,
+			&i.EventsQuota,
 		); err != nil {
 			return nil, err
 		}
@@ -87,12 +102,49 @@ func (q *Queries) ListBoxes(ctx context.Context) ([]Box, error) {
 	return items, nil
 }
 
+const restoreBox = `-- name: RestoreBox :one
+INSERT INTO boxes (id, name, containers, created_at, container_settings, scope) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id, name, containers, created_at, last_accessed_at, container_settings, scope, records_quota, events_quota
+`
+
+type RestoreBoxParams struct {
+	ID                uuid.UUID    `json:"id"`
+	Name              string       `json:"name"`
+	Containers        []string     `json:"containers"`
+	CreatedAt         time.Time    `json:"created_at"`
+	ContainerSettings pgtype.JSONB `json:"container_settings"`
+	Scope             pgtype.JSONB `json:"scope"`
+}
+
+func (q *Queries) RestoreBox(ctx context.Context, arg RestoreBoxParams) (Box, error) {
+	row := q.db.QueryRow(ctx, restoreBox,
+		arg.ID,
+		arg.Name,
+		arg.Containers,
+		arg.CreatedAt,
+		arg.ContainerSettings,
+		arg.Scope,
+	)
+	var i Box
+	err := row.Scan(
+		&i.ID,
+		&i.Name,
+		&i.Containers,
+		&i.CreatedAt,
+		&i.LastAccessedAt,
+		&i.ContainerSettings,
+		&i.Scope,
+		&i.RecordsQuota,
+		&i.EventsQuota,
+	)
+	return i, err
+}
+
 const updateBox = `-- name: UpdateBox :exec
 UPDATE boxes SET
     name=$1, containers=$2
 WHERE
     id=$3
-RETURNING id, name, containers, created_at, last_accessed_at
+RETURNING id, name, containers, created_at, last_accessed_at, container_settings, scope, records_quota, events_quota
 `
 
 type UpdateBoxParams struct {
@@ -106,6 +158,49 @@ func (q *Queries) UpdateBox(ctx context.Context, arg UpdateBoxParams) error {
 	return err
 }
 
+const updateBoxContainerSettings = `-- name: UpdateBoxContainerSettings :exec
+UPDATE boxes SET container_settings = $1 WHERE id = $2
+`
+
+type UpdateBoxContainerSettingsParams struct {
+	ContainerSettings pgtype.JSONB `json:"container_settings"`
+	ID                uuid.UUID    `json:"id"`
+}
+
+func (q *Queries) UpdateBoxContainerSettings(ctx context.Context, arg UpdateBoxContainerSettingsParams) error {
+	_, err := q.db.Exec(ctx, updateBoxContainerSettings, arg.ContainerSettings, arg.ID)
+	return err
+}
+
+const updateBoxQuota = `-- name: UpdateBoxQuota :exec
+UPDATE boxes SET records_quota = $1, events_quota = $2 WHERE id = $3
+`
+
+type UpdateBoxQuotaParams struct {
+	RecordsQuota sql.NullInt64 `json:"records_quota"`
+	EventsQuota  sql.NullInt64 `json:"events_quota"`
+	ID           uuid.UUID     `json:"id"`
+}
+
+func (q *Queries) UpdateBoxQuota(ctx context.Context, arg UpdateBoxQuotaParams) error {
+	_, err := q.db.Exec(ctx, updateBoxQuota, arg.RecordsQuota, arg.EventsQuota, arg.ID)
+	return err
+}
+
+const updateBoxScope = `-- name: UpdateBoxScope :exec
+UPDATE boxes SET scope = $1 WHERE id = $2
+`
+
+type UpdateBoxScopeParams struct {
+	Scope pgtype.JSONB `json:"scope"`
+	ID    uuid.UUID    `json:"id"`
+}
+
+func (q *Queries) UpdateBoxScope(ctx context.Context, arg UpdateBoxScopeParams) error {
+	_, err := q.db.Exec(ctx, updateBoxScope, arg.Scope, arg.ID)
+	return err
+}
+
 const updateLastAccessed = `-- name: UpdateLastAccessed :exec
 UPDATE boxes SET last_accessed_at = NOW() WHERE id = $1
 `
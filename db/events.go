@@ -0,0 +1,52 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+// ErrEventsQuota is returned by ScheduleAutomationEvents if the events would
+// exceed the events quota of the box. None of the events are created then.
+var ErrEventsQuota = errors.New("events quota exceeded")
+
+// ScheduleAutomationEvents creates a scheduled event of automation for every
+// entry of data. The events are created all at once, unless they would exceed
+// quota together with the events of the box. A quota of zero means unlimited.
+func ScheduleAutomationEvents(ctx context.Context, dbPool *pgxpool.Pool, automation Automation, data []string, quota int64) (int64, error) {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// the box is locked until the events are committed, so that concurrent
+	// runs can not exceed the quota together
+	if _, err := tx.Exec(ctx, lockBox, automation.BoxID); err != nil {
+		return 0, err
+	}
+
+	q := New(tx)
+
+	count, err := q.CountAutomationEvents(ctx, automation.BoxID)
+	if err != nil {
+		return 0, err
+	}
+
+	if quota > 0 && count+int64(len(data)) > quota {
+		return 0, fmt.Errorf("%w: %d of %d events", ErrEventsQuota, count+int64(len(data)), quota)
+	}
+
+	created, err := q.CreateAutomationEvents(ctx, CreateAutomationEventsParams{
+		BoxID:        automation.BoxID,
+		AutomationID: automation.ID,
+		Data:         data,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return created, tx.Commit(ctx)
+}
@@ -22,6 +22,7 @@ type Automation struct {
 	DestinationTags      []string  `json:"destination_tags"`
 	IsPublic             bool      `json:"is_public"`
 	CreatedAt            time.Time `json:"created_at"`
+	SourceTerm           string    `json:"source_term"`
 }
 
 type AutomationEvent struct {
@@ -34,14 +35,19 @@ type AutomationEvent struct {
 	CreatedAt    time.Time    `json:"created_at"`
 	StartedAt    sql.NullTime `json:"started_at"`
 	FinishedAt   sql.NullTime `json:"finished_at"`
+	Report       pgtype.JSONB `json:"report"`
 }
 
 type Box struct {
-	ID             uuid.UUID `json:"id"`
-	Name           string    `json:"name"`
-	Containers     []string  `json:"containers"`
-	CreatedAt      time.Time `json:"created_at"`
-	LastAccessedAt time.Time `json:"last_accessed_at"`
+	ID                uuid.UUID     `json:"id"`
+	Name              string        `json:"name"`
+	Containers        []string      `json:"containers"`
+	CreatedAt         time.Time     `json:"created_at"`
+	LastAccessedAt    time.Time     `json:"last_accessed_at"`
+	ContainerSettings pgtype.JSONB  `json:"container_settings"`
+	Scope             pgtype.JSONB  `json:"scope"`
+	RecordsQuota      sql.NullInt64 `json:"records_quota"`
+	EventsQuota       sql.NullInt64 `json:"events_quota"`
 }
 
 type GueFinishedJob struct {
@@ -70,9 +76,55 @@ type GueJob struct {
 }
 
 type Record struct {
-	Data      string    `json:"data"`
-	Tags      []string  `json:"tags"`
-	BoxID     uuid.UUID `json:"box_id"`
-	Container string    `json:"container"`
-	CreatedAt time.Time `json:"created_at"`
+	Data       string       `json:"data"`
+	Tags       []string     `json:"tags"`
+	BoxID      uuid.UUID    `json:"box_id"`
+	Container  string       `json:"container"`
+	CreatedAt  time.Time    `json:"created_at"`
+	Attributes pgtype.JSONB `json:"attributes"`
+	LastSeenAt time.Time    `json:"last_seen_at"`
+	SeenCount  int32        `json:"seen_count"`
+	DataHash   []byte       `json:"-"`
+}
+
+type RecordEdge struct {
+	BoxID           uuid.UUID     `json:"box_id"`
+	SourceContainer string        `json:"source_container"`
+	SourceData      string        `json:"source_data"`
+	TargetContainer string        `json:"target_container"`
+	TargetData      string        `json:"target_data"`
+	AutomationID    uuid.NullUUID `json:"automation_id"`
+	CreatedAt       time.Time     `json:"created_at"`
+	SourceHash      []byte        `json:"-"`
+	TargetHash      []byte        `json:"-"`
+}
+
+type RecordSource struct {
+	BoxID        uuid.UUID     `json:"box_id"`
+	Container    string        `json:"container"`
+	Data         string        `json:"data"`
+	Kind         string        `json:"kind"`
+	EventID      uuid.NullUUID `json:"event_id"`
+	AutomationID uuid.NullUUID `json:"automation_id"`
+	Command      string        `json:"command"`
+	Input        string        `json:"input"`
+	CreatedAt    time.Time     `json:"created_at"`
+	DataHash     []byte        `json:"-"`
+}
+
+type Snapshot struct {
+	ID           uuid.UUID     `json:"id"`
+	BoxID        uuid.UUID     `json:"box_id"`
+	Name         string        `json:"name"`
+	Kind         string        `json:"kind"`
+	AutomationID uuid.NullUUID `json:"automation_id"`
+	CreatedAt    time.Time     `json:"created_at"`
+}
+
+type SnapshotRecord struct {
+	SnapshotID uuid.UUID `json:"snapshot_id"`
+	Container  string    `json:"container"`
+	Data       string    `json:"data"`
+	DataHash   []byte    `json:"-"`
+	Tags       []string  `json:"tags"`
 }
@@ -9,33 +9,74 @@ import (
 )
 
 type Querier interface {
+	AddRecordTags(ctx context.Context, arg AddRecordTagsParams) error
+	CopySnapshotRecords(ctx context.Context, arg CopySnapshotRecordsParams) (int64, error)
 	CountAutomationEvents(ctx context.Context, boxID uuid.UUID) (int64, error)
 	CountRecordsByBox(ctx context.Context, boxID uuid.UUID) (int64, error)
-	CountRecordsByBoxFilter(ctx context.Context, arg CountRecordsByBoxFilterParams) (int64, error)
+	CountRecordsByContainer(ctx context.Context, arg CountRecordsByContainerParams) (int64, error)
+	CountRecordsExceedingTags(ctx context.Context, arg CountRecordsExceedingTagsParams) (int64, error)
+	CountSnapshotRecordsByBox(ctx context.Context, boxID uuid.UUID) (int64, error)
+	CountSnapshots(ctx context.Context, arg CountSnapshotsParams) (int64, error)
 	CreateAutomation(ctx context.Context, arg CreateAutomationParams) (Automation, error)
 	CreateAutomationEvent(ctx context.Context, arg CreateAutomationEventParams) (AutomationEvent, error)
+	CreateAutomationEvents(ctx context.Context, arg CreateAutomationEventsParams) (int64, error)
 	CreateBox(ctx context.Context, arg CreateBoxParams) (Box, error)
 	CreateRecord(ctx context.Context, arg CreateRecordParams) error
+	CreateSnapshot(ctx context.Context, arg CreateSnapshotParams) (Snapshot, error)
 	DeleteAutomation(ctx context.Context, id uuid.UUID) error
 	DeleteAutomationEvents(ctx context.Context, arg DeleteAutomationEventsParams) error
 	DeleteAutomationEventsOld(ctx context.Context) error
+	DeleteAutomationTag(ctx context.Context, arg DeleteAutomationTagParams) error
+	DeleteAutomationsByContainer(ctx context.Context, arg DeleteAutomationsByContainerParams) (int64, error)
 	DeleteBox(ctx context.Context, id uuid.UUID) error
+	DeleteBoxContainer(ctx context.Context, arg DeleteBoxContainerParams) error
+	DeleteExpiredRecords(ctx context.Context, arg DeleteExpiredRecordsParams) (int64, error)
+	DeleteRecordTag(ctx context.Context, arg DeleteRecordTagParams) (int64, error)
 	DeleteRecords(ctx context.Context, arg DeleteRecordsParams) error
+	DeleteRecordsByContainer(ctx context.Context, arg DeleteRecordsByContainerParams) (int64, error)
+	DeleteSnapshot(ctx context.Context, arg DeleteSnapshotParams) (int64, error)
+	DeleteSnapshotsOld(ctx context.Context, arg DeleteSnapshotsOldParams) error
 	DequeueAutomationEvents(ctx context.Context, arg DequeueAutomationEventsParams) ([]AutomationEvent, error)
+	DiffSnapshot(ctx context.Context, arg DiffSnapshotParams) ([]DiffSnapshotRow, error)
 	GetAutomation(ctx context.Context, id uuid.UUID) (Automation, error)
 	GetAutomationEvent(ctx context.Context, id uuid.UUID) (AutomationEvent, error)
 	GetAutomationEventCounts(ctx context.Context, boxID uuid.UUID) ([]GetAutomationEventCountsRow, error)
 	GetBox(ctx context.Context, id uuid.UUID) (Box, error)
+	GetSnapshot(ctx context.Context, id uuid.UUID) (Snapshot, error)
 	ListAutomationEvents(ctx context.Context, arg ListAutomationEventsParams) ([]AutomationEvent, error)
+	ListAutomationEventsByBox(ctx context.Context, boxID uuid.UUID) ([]AutomationEvent, error)
 	ListAutomationLibrary(ctx context.Context) ([]ListAutomationLibraryRow, error)
 	ListAutomations(ctx context.Context, boxID uuid.UUID) ([]Automation, error)
 	ListBoxes(ctx context.Context) ([]Box, error)
+	ListRecordChildren(ctx context.Context, arg ListRecordChildrenParams) ([]ListRecordChildrenRow, error)
+	ListRecordEdgesByBox(ctx context.Context, boxID uuid.UUID) ([]RecordEdge, error)
+	ListRecordParents(ctx context.Context, arg ListRecordParentsParams) ([]ListRecordParentsRow, error)
+	ListRecordSources(ctx context.Context, arg ListRecordSourcesParams) ([]RecordSource, error)
+	ListRecordSourcesByBox(ctx context.Context, boxID uuid.UUID) ([]RecordSource, error)
 	ListRecordsByBoxFilter(ctx context.Context, arg ListRecordsByBoxFilterParams) ([]Record, error)
-	ListRecordsByBoxFilterPaginated(ctx context.Context, arg ListRecordsByBoxFilterPaginatedParams) ([]Record, error)
+	ListRecordsByEvent(ctx context.Context, arg ListRecordsByEventParams) ([]Record, error)
+	ListSnapshots(ctx context.Context, boxID uuid.UUID) ([]ListSnapshotsRow, error)
+	ListTagCounts(ctx context.Context, boxID uuid.UUID) ([]ListTagCountsRow, error)
+	RemoveRecordTags(ctx context.Context, arg RemoveRecordTagsParams) error
+	RenameAutomationContainer(ctx context.Context, arg RenameAutomationContainerParams) error
+	RenameAutomationTag(ctx context.Context, arg RenameAutomationTagParams) error
+	RenameBoxContainer(ctx context.Context, arg RenameBoxContainerParams) error
+	RenameRecordContainer(ctx context.Context, arg RenameRecordContainerParams) (int64, error)
+	RenameRecordTag(ctx context.Context, arg RenameRecordTagParams) (int64, error)
+	RenameSnapshotContainer(ctx context.Context, arg RenameSnapshotContainerParams) error
+	RestoreAutomation(ctx context.Context, arg RestoreAutomationParams) error
+	RestoreAutomationEvent(ctx context.Context, arg RestoreAutomationEventParams) error
+	RestoreBox(ctx context.Context, arg RestoreBoxParams) (Box, error)
+	RestoreRecordEdge(ctx context.Context, arg RestoreRecordEdgeParams) error
+	RestoreRecordSource(ctx context.Context, arg RestoreRecordSourceParams) error
 	UpdateAutomation(ctx context.Context, arg UpdateAutomationParams) error
 	UpdateAutomationEventStatus(ctx context.Context, arg UpdateAutomationEventStatusParams) error
 	UpdateAutomationEventStatusFinished(ctx context.Context, arg UpdateAutomationEventStatusFinishedParams) error
+	UpdateAutomationSourceTerm(ctx context.Context, arg UpdateAutomationSourceTermParams) error
 	UpdateBox(ctx context.Context, arg UpdateBoxParams) error
+	UpdateBoxContainerSettings(ctx context.Context, arg UpdateBoxContainerSettingsParams) error
+	UpdateBoxQuota(ctx context.Context, arg UpdateBoxQuotaParams) error
+	UpdateBoxScope(ctx context.Context, arg UpdateBoxScopeParams) error
 	UpdateLastAccessed(ctx context.Context, id uuid.UUID) error
 	UpdateRecordTags(ctx context.Context, arg UpdateRecordTagsParams) error
 }
@@ -6,7 +6,7 @@ SELECT status, count(*) FROM automation_events WHERE box_id = $1 group by status
 
 -- name: ListAutomationLibrary :many
 SELECT 
-    id, name, description, command, source_container, source_tags, destination_container, destination_tags, is_public
+    id, name, description, command, source_container, source_tags, destination_container, destination_tags, is_public, source_term
 FROM automations
 WHERE is_public = true;
 
@@ -21,8 +21,12 @@ UPDATE automations SET
     source_tags=$4,
     destination_container=$5,
     destination_tags=$6,
-    command=$7
-WHERE id = $8;
+    command=$7,
+    source_term=$8
+WHERE id = $9;
+
+-- name: UpdateAutomationSourceTerm :exec
+UPDATE automations SET source_term = $1 WHERE id = $2;
 
 -- name: GetAutomationEvent :one
 SELECT * FROM automation_events WHERE id = $1 LIMIT 1;
@@ -32,11 +36,16 @@ INSERT INTO automation_events (
     box_id, automation_id, data, status, affected_rows
 ) VALUES ($1, $2, $3, $4, $5) RETURNING *; 
 
+-- name: CreateAutomationEvents :execrows
+INSERT INTO automation_events (
+    box_id, automation_id, data, status
+) SELECT sqlc.arg(box_id)::uuid, sqlc.arg(automation_id)::uuid, unnest(sqlc.arg(data)::text[]), 'scheduled';
+
 -- name: UpdateAutomationEventStatus :exec
 UPDATE automation_events SET status = $1 where id = $2;
 
 -- name: UpdateAutomationEventStatusFinished :exec
-UPDATE automation_events SET status = $1, affected_rows = $2, finished_at = now() where id = $3;
+UPDATE automation_events SET status = $1, affected_rows = $2, report = $3, finished_at = now() where id = $4;
 
 -- name: ListAutomationEvents :many
 SELECT * FROM automation_events WHERE automation_id = $1 ORDER BY created_at DESC LIMIT $2;
@@ -55,8 +64,8 @@ WHERE id IN (
 
 -- name: CreateAutomation :one
 INSERT INTO automations (
-    name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public
-) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING *;
+    name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public, source_term
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING *;
 
 -- name: DeleteAutomation :exec
 DELETE FROM automations WHERE id = $1;
@@ -69,3 +78,16 @@ DELETE FROM automation_events WHERE box_id = $1 AND status = $2;
 
 -- name: CountAutomationEvents :one
 SELECT count(*) from automation_events WHERE box_id = $1;
+
+-- name: ListAutomationEventsByBox :many
+SELECT * FROM automation_events WHERE box_id = $1 ORDER BY created_at;
+
+-- name: RestoreAutomation :exec
+INSERT INTO automations (
+    id, name, description, box_id, command, source_container, source_tags, destination_container, destination_tags, is_public, created_at, source_term
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12);
+
+-- name: RestoreAutomationEvent :exec
+INSERT INTO automation_events (
+    id, box_id, automation_id, status, data, affected_rows, created_at, started_at, finished_at, report
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10);
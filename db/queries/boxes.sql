@@ -14,8 +14,20 @@ WHERE
     id=$3
 RETURNING *;
 
+-- name: UpdateBoxContainerSettings :exec
+UPDATE boxes SET container_settings = $1 WHERE id = $2;
+
+-- name: UpdateBoxQuota :exec
+UPDATE boxes SET records_quota = $1, events_quota = $2 WHERE id = $3;
+
+-- name: UpdateBoxScope :exec
+UPDATE boxes SET scope = $1 WHERE id = $2;
+
 -- name: UpdateLastAccessed :exec
 UPDATE boxes SET last_accessed_at = NOW() WHERE id = $1;
 
 -- name: DeleteBox :exec
 DELETE FROM boxes WHERE id = $1;
+
+-- name: RestoreBox :one
+INSERT INTO boxes (id, name, containers, created_at, container_settings, scope) VALUES ($1, $2, $3, $4, $5, $6) RETURNING *;
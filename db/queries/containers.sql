@@ -0,0 +1,34 @@
+-- name: RenameBoxContainer :exec
+UPDATE boxes SET
+    containers = array_replace(containers, sqlc.arg(container)::varchar, sqlc.arg(new_container)::varchar)
+WHERE
+    id = sqlc.arg(box_id);
+
+-- name: RenameRecordContainer :execrows
+UPDATE records SET
+    container = sqlc.arg(new_container)::varchar
+WHERE
+    box_id = sqlc.arg(box_id) AND container = sqlc.arg(container)::varchar;
+
+-- name: RenameAutomationContainer :exec
+UPDATE automations SET
+    source_container = CASE WHEN source_container = sqlc.arg(container)::text
+        THEN sqlc.arg(new_container)::text ELSE source_container END,
+    destination_container = CASE WHEN destination_container = sqlc.arg(container)::text
+        THEN sqlc.arg(new_container)::text ELSE destination_container END
+WHERE
+    box_id = sqlc.arg(box_id);
+
+-- name: DeleteBoxContainer :exec
+UPDATE boxes SET
+    containers = array_remove(containers, sqlc.arg(container)::varchar)
+WHERE
+    id = sqlc.arg(box_id);
+
+-- name: DeleteRecordsByContainer :execrows
+DELETE FROM records WHERE box_id = $1 AND container = $2;
+
+-- name: DeleteAutomationsByContainer :execrows
+DELETE FROM automations
+WHERE
+    box_id = sqlc.arg(box_id) AND sqlc.arg(container)::text IN (source_container, destination_container);
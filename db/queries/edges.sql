@@ -0,0 +1,22 @@
+-- name: ListRecordChildren :many
+SELECT r.*, e.automation_id FROM record_edges e
+JOIN records r ON r.box_id = e.box_id AND r.container = e.target_container AND r.data_hash = e.target_hash
+WHERE
+    e.box_id = $1 AND e.source_container = $2 AND e.source_hash = record_hash(sqlc.arg(source_data))
+ORDER BY r.container, r.data;
+
+-- name: ListRecordParents :many
+SELECT r.*, e.automation_id FROM record_edges e
+JOIN records r ON r.box_id = e.box_id AND r.container = e.source_container AND r.data_hash = e.source_hash
+WHERE
+    e.box_id = $1 AND e.target_container = $2 AND e.target_hash = record_hash(sqlc.arg(target_data))
+ORDER BY r.container, r.data;
+
+-- name: ListRecordEdgesByBox :many
+SELECT * FROM record_edges WHERE box_id = $1;
+
+-- name: RestoreRecordEdge :exec
+INSERT INTO record_edges (
+    box_id, source_container, source_data, target_container, target_data, automation_id, created_at,
+    source_hash, target_hash
+) VALUES ($1, $2, $3, $4, $5, $6, $7, record_hash($3), record_hash($5)) ON CONFLICT DO NOTHING;
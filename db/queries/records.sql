@@ -2,30 +2,18 @@
 SELECT * FROM records WHERE 
     box_id = $1 AND
     container = $2 AND
-    $3::varchar[] <@ tags AND
+    tags @> $3::varchar[] AND
     data LIKE $4 
 ORDER BY created_at DESC, data, tags;
 
--- name: ListRecordsByBoxFilterPaginated :many
-SELECT * FROM records WHERE 
-    box_id = $1 AND
-    container = $2 AND
-    $3::varchar[] <@ tags AND
-    data LIKE $4
-ORDER BY created_at DESC, data, tags
-LIMIT $5 OFFSET $6;
-
--- name: CountRecordsByBoxFilter :one
-SELECT count(*) FROM records WHERE 
-    box_id = $1 AND
-    container = $2 AND
-    $3::varchar[] <@ tags AND
-    data LIKE $4;
-
 -- name: CountRecordsByBox :one
 SELECT count(*) FROM records WHERE 
     box_id = $1; 
 
+-- name: CountRecordsByContainer :one
+SELECT count(*) FROM records WHERE
+    box_id = $1 AND container = $2;
+
 -- name: CreateRecord :exec
 INSERT INTO records (data, tags, box_id, container) VALUES ($1, $2, $3, $4);
 
@@ -33,10 +21,40 @@ INSERT INTO records (data, tags, box_id, container) VALUES ($1, $2, $3, $4);
 UPDATE records SET
     tags = $1
 WHERE
-    box_id = $2 AND container = $3 AND data = ANY($4::varchar[]);
+    box_id = $2 AND container = $3 AND data_hash = ANY(ARRAY(SELECT record_hash(d) FROM unnest($4::varchar[]) d));
 
 -- name: DeleteRecords :exec
 DELETE FROM
     records
 WHERE
-    box_id = $1 AND container = $2 AND data = ANY($3::varchar[]);
+    box_id = $1 AND container = $2 AND data_hash = ANY(ARRAY(SELECT record_hash(d) FROM unnest($3::varchar[]) d));
+
+-- name: DeleteExpiredRecords :execrows
+DELETE FROM
+    records
+WHERE
+    box_id = sqlc.arg(box_id) AND container = sqlc.arg(container) AND
+    last_seen_at < NOW() - make_interval(days => sqlc.arg(days)::int) AND
+    (sqlc.arg(tag)::varchar = '' OR tags @> ARRAY[sqlc.arg(tag)::varchar]);
+
+-- name: AddRecordTags :exec
+UPDATE records SET
+    tags = COALESCE(tags, '{}') || ARRAY(
+        SELECT t FROM unnest($1::varchar[]) t WHERE NOT t = ANY(COALESCE(tags, '{}'))
+    )
+WHERE
+    box_id = $2 AND container = $3 AND data_hash = ANY(ARRAY(SELECT record_hash(d) FROM unnest($4::varchar[]) d));
+
+-- name: CountRecordsExceedingTags :one
+SELECT count(*) FROM records
+WHERE
+    box_id = $2 AND container = $3 AND data_hash = ANY(ARRAY(SELECT record_hash(d) FROM unnest($4::varchar[]) d)) AND
+    cardinality(COALESCE(tags, '{}') || ARRAY(
+        SELECT t FROM unnest($1::varchar[]) t WHERE NOT t = ANY(COALESCE(tags, '{}'))
+    )) > sqlc.arg(tags_max)::int;
+
+-- name: RemoveRecordTags :exec
+UPDATE records SET
+    tags = ARRAY(SELECT t FROM unnest(tags) t WHERE NOT t = ANY($1::varchar[]))
+WHERE
+    box_id = $2 AND container = $3 AND data_hash = ANY(ARRAY(SELECT record_hash(d) FROM unnest($4::varchar[]) d));
@@ -0,0 +1,61 @@
+-- name: ListSnapshots :many
+SELECT
+    s.*,
+    (SELECT count(*) FROM snapshot_records r WHERE r.snapshot_id = s.id) AS records
+FROM snapshots s
+WHERE s.box_id = $1
+ORDER BY s.created_at DESC;
+
+-- name: GetSnapshot :one
+SELECT * FROM snapshots WHERE id = $1 LIMIT 1;
+
+-- name: CountSnapshotRecordsByBox :one
+SELECT count(*) FROM snapshot_records WHERE snapshot_id IN (SELECT id FROM snapshots WHERE box_id = $1);
+
+-- name: CountSnapshots :one
+SELECT count(*) FROM snapshots WHERE box_id = $1 AND kind = $2;
+
+-- name: CreateSnapshot :one
+INSERT INTO snapshots (box_id, name, kind, automation_id) VALUES ($1, $2, $3, $4) RETURNING *;
+
+-- name: CopySnapshotRecords :execrows
+INSERT INTO snapshot_records (snapshot_id, container, data, tags)
+SELECT $1, container, data, tags FROM records WHERE box_id = $2;
+
+-- name: DeleteSnapshot :execrows
+DELETE FROM snapshots WHERE id = $1 AND box_id = $2;
+
+-- name: DeleteSnapshotsOld :exec
+DELETE FROM snapshots
+WHERE
+    box_id = $1 AND kind = $2 AND id NOT IN (
+        SELECT id FROM snapshots WHERE box_id = $1 AND kind = $2 ORDER BY created_at DESC LIMIT $3
+    );
+
+-- name: RenameSnapshotContainer :exec
+UPDATE snapshot_records SET
+    container = sqlc.arg(new_container)::varchar
+WHERE
+    container = sqlc.arg(container)::varchar AND
+    snapshot_id IN (SELECT id FROM snapshots WHERE box_id = sqlc.arg(box_id));
+
+-- name: DiffSnapshot :many
+WITH a AS (
+    SELECT container, data, data_hash, tags FROM snapshot_records WHERE snapshot_id = sqlc.arg(from_id)
+), b AS (
+    SELECT container, data, data_hash, tags FROM snapshot_records WHERE snapshot_id = sqlc.arg(to_id)
+    UNION ALL
+    SELECT container, data, data_hash, tags FROM records WHERE box_id = sqlc.arg(box_id) AND sqlc.arg(to_id)::uuid IS NULL
+)
+SELECT
+    COALESCE(a.container, b.container)::varchar AS container,
+    COALESCE(a.data, b.data)::varchar AS data,
+    (CASE WHEN a.data IS NULL THEN 'added' WHEN b.data IS NULL THEN 'removed' ELSE 'tags_changed' END)::text AS change,
+    a.tags AS old_tags,
+    b.tags AS new_tags
+FROM a FULL JOIN b ON a.container = b.container AND a.data_hash = b.data_hash
+WHERE
+    a.data IS NULL OR b.data IS NULL OR
+    NOT (COALESCE(a.tags, '{}') @> COALESCE(b.tags, '{}') AND COALESCE(a.tags, '{}') <@ COALESCE(b.tags, '{}'))
+ORDER BY container, change, data
+LIMIT $4 OFFSET $5;
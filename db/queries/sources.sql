@@ -0,0 +1,18 @@
+-- name: ListRecordSources :many
+SELECT * FROM record_sources WHERE
+    box_id = $1 AND container = $2 AND data_hash = ANY(ARRAY(SELECT record_hash(d) FROM unnest($3::varchar[]) d))
+ORDER BY created_at;
+
+-- name: ListRecordsByEvent :many
+SELECT r.* FROM record_sources s
+JOIN records r ON r.box_id = s.box_id AND r.container = s.container AND r.data_hash = s.data_hash
+WHERE s.box_id = $1 AND s.event_id = $2
+ORDER BY r.container, r.data;
+
+-- name: ListRecordSourcesByBox :many
+SELECT * FROM record_sources WHERE box_id = $1;
+
+-- name: RestoreRecordSource :exec
+INSERT INTO record_sources (
+    box_id, container, data, kind, event_id, automation_id, command, input, created_at, data_hash
+) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, record_hash($3)) ON CONFLICT DO NOTHING;
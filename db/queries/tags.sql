@@ -0,0 +1,40 @@
+-- name: ListTagCounts :many
+SELECT container, t::varchar AS tag, count(*) AS count
+FROM records, unnest(tags) t
+WHERE box_id = $1
+GROUP BY container, t
+ORDER BY container, t;
+
+-- name: RenameRecordTag :execrows
+UPDATE records SET
+    tags = CASE WHEN sqlc.arg(new_tag)::varchar = ANY(tags)
+        THEN array_remove(tags, sqlc.arg(tag)::varchar)
+        ELSE array_replace(tags, sqlc.arg(tag)::varchar, sqlc.arg(new_tag)::varchar)
+    END
+WHERE
+    box_id = sqlc.arg(box_id) AND tags @> ARRAY[sqlc.arg(tag)::varchar];
+
+-- name: RenameAutomationTag :exec
+UPDATE automations SET
+    source_tags = CASE WHEN sqlc.arg(new_tag)::text = ANY(source_tags)
+        THEN array_remove(source_tags, sqlc.arg(tag)::text)
+        ELSE array_replace(source_tags, sqlc.arg(tag)::text, sqlc.arg(new_tag)::text)
+    END,
+    destination_tags = CASE WHEN sqlc.arg(new_tag)::text = ANY(destination_tags)
+        THEN array_remove(destination_tags, sqlc.arg(tag)::text)
+        ELSE array_replace(destination_tags, sqlc.arg(tag)::text, sqlc.arg(new_tag)::text)
+    END
+WHERE
+    box_id = sqlc.arg(box_id);
+
+-- name: DeleteRecordTag :execrows
+UPDATE records SET
+    tags = array_remove(tags, $2::varchar)
+WHERE
+    box_id = $1 AND tags @> ARRAY[$2::varchar];
+
+-- name: DeleteAutomationTag :exec
+UPDATE automations SET
+    destination_tags = array_remove(destination_tags, $2::text)
+WHERE
+    box_id = $1;
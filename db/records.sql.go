@@ -9,6 +9,32 @@ import (
 	"github.com/google/uuid"
 )
 
+const addRecordTags = `-- name: AddRecordTags :exec
+UPDATE records SET
+    tags = COALESCE(tags, '{}') || ARRAY(
+        SELECT t FROM unnest($1::varchar[]) t WHERE NOT t = ANY(COALESCE(tags, '{}'))
+    )
+WHERE
+    box_id = $2 AND container = $3 AND data_hash = ANY(ARRAY(SELECT record_hash(d) FROM unnest($4::varchar[]) d))
+`
+
+type AddRecordTagsParams struct {
+	Column1   []string  `json:"column_1"`
+	BoxID     uuid.UUID `json:"box_id"`
+	Container string    `json:"container"`
+	Column4   []string  `json:"column_4"`
+}
+
+func (q *Queries) AddRecordTags(ctx context.Context, arg AddRecordTagsParams) error {
+	_, err := q.db.Exec(ctx, addRecordTags,
+		arg.Column1,
+		arg.BoxID,
+		arg.Container,
+		arg.Column4,
+	)
+	return err
+}
+
 const countRecordsByBox = `-- name: CountRecordsByBox :one
 SELECT count(*) FROM records WHERE 
     box_id = $1
@@ -21,27 +47,47 @@ func (q *Queries) CountRecordsByBox(ctx context.Context, boxID uuid.UUID) (int64
 	return count, err
 }
 
-const countRecordsByBoxFilter = `-- name: CountRecordsByBoxFilter :one
-SELECT count(*) FROM records WHERE 
-    box_id = $1 AND
-    container = $2 AND
-    $3::varchar[] <@ tags AND
-    data LIKE $4
+const countRecordsByContainer = `-- name: CountRecordsByContainer :one
+SELECT count(*) FROM records WHERE
+    box_id = $1 AND container = $2
 `
 
-type CountRecordsByBoxFilterParams struct {
+type CountRecordsByContainerParams struct {
 	BoxID     uuid.UUID `json:"box_id"`
 	Container string    `json:"container"`
-	Column3   []string  `json:"column_3"`
-	Data      string    `json:"data"`
 }
 
-func (q *Queries) CountRecordsByBoxFilter(ctx context.Context, arg CountRecordsByBoxFilterParams) (int64, error) {
-	row := q.db.QueryRow(ctx, countRecordsByBoxFilter,
+func (q *Queries) CountRecordsByContainer(ctx context.Context, arg CountRecordsByContainerParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countRecordsByContainer, arg.BoxID, arg.Container)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countRecordsExceedingTags = `-- name: CountRecordsExceedingTags :one
+SELECT count(*) FROM records
+WHERE
+    box_id = $2 AND container = $3 AND data_hash = ANY(ARRAY(SELECT record_hash(d) FROM unnest($4::varchar[]) d)) AND
+    cardinality(COALESCE(tags, '{}') || ARRAY(
+        SELECT t FROM unnest($1::varchar[]) t WHERE NOT t = ANY(COALESCE(tags, '{}'))
+    )) > $5::int
+`
+
+type CountRecordsExceedingTagsParams struct {
+	Column1   []string  `json:"column_1"`
+	BoxID     uuid.UUID `json:"box_id"`
+	Container string    `json:"container"`
+	Column4   []string  `json:"column_4"`
+	TagsMax   int32     `json:"tags_max"`
+}
+
+func (q *Queries) CountRecordsExceedingTags(ctx context.Context, arg CountRecordsExceedingTagsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countRecordsExceedingTags,
+		arg.Column1,
 		arg.BoxID,
 		arg.Container,
-		arg.Column3,
-		arg.Data,
+		arg.Column4,
+		arg.TagsMax,
 	)
 	var count int64
 	err := row.Scan(&count)
@@ -69,11 +115,40 @@ func (q *Queries) CreateRecord(ctx context.Context, arg CreateRecordParams) erro
 	return err
 }
 
+const deleteExpiredRecords = `-- name: DeleteExpiredRecords :execrows
+DELETE FROM
+    records
+WHERE
+    box_id = $1 AND container = $2 AND
+    last_seen_at < NOW() - make_interval(days => $3::int) AND
+    ($4::varchar = '' OR tags @> ARRAY[$4::varchar])
+`
+
+type DeleteExpiredRecordsParams struct {
+	BoxID     uuid.UUID `json:"box_id"`
+	Container string    `json:"container"`
+	Days      int32     `json:"days"`
+	Tag       string    `json:"tag"`
+}
+
+func (q *Queries) DeleteExpiredRecords(ctx context.Context, arg DeleteExpiredRecordsParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteExpiredRecords,
+		arg.BoxID,
+		arg.Container,
+		arg.Days,
+		arg.Tag,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const deleteRecords = `-- name: DeleteRecords :exec
 DELETE FROM
     records
 WHERE
-    box_id = $1 AND container = $2 AND data = ANY($3::varchar[])
+    box_id = $1 AND container = $2 AND data_hash = ANY(ARRAY(SELECT record_hash(d) FROM unnest($3::varchar[]) d))
 `
 
 type DeleteRecordsParams struct {
@@ -88,10 +163,10 @@ func (q *Queries) DeleteRecords(ctx context.Context, arg DeleteRecordsParams) er
 }
 
 const listRecordsByBoxFilter = `-- name: ListRecordsByBoxFilter :many
-SELECT data, tags, box_id, container, created_at FROM records WHERE 
+SELECT data, tags, box_id, container, created_at, attributes, last_seen_at, seen_count, data_hash FROM records WHERE 
     box_id = $1 AND
     container = $2 AND
-    $3::varchar[] <@ tags AND
+    tags @> $3::varchar[] AND
     data LIKE $4 
 ORDER BY created_at DESC, data, tags
 `
@@ -123,6 +198,10 @@ func (q *Queries) ListRecordsByBoxFilter(ctx context.Context, arg ListRecordsByB
 			&i.BoxID,
 			&i.Container,
 			&i.CreatedAt,
+			&i.Attributes,
+			&i.LastSeenAt,
+			&i.SeenCount,
+			&i.DataHash,
 		); err != nil {
 			return nil, err
 		}
@@ -134,63 +213,35 @@ func (q *Queries) ListRecordsByBoxFilter(ctx context.Context, arg ListRecordsByB
 	return items, nil
 }
 
-const listRecordsByBoxFilterPaginated = `-- name: ListRecordsByBoxFilterPaginated :many
-SELECT data, tags, box_id, container, created_at FROM records WHERE 
-    box_id = $1 AND
-    container = $2 AND
-    $3::varchar[] <@ tags AND
-    data LIKE $4
-ORDER BY created_at DESC, data, tags
-LIMIT $5 OFFSET $6
+const removeRecordTags = `-- name: RemoveRecordTags :exec
+UPDATE records SET
+    tags = ARRAY(SELECT t FROM unnest(tags) t WHERE NOT t = ANY($1::varchar[]))
+WHERE
+    box_id = $2 AND container = $3 AND data_hash = ANY(ARRAY(SELECT record_hash(d) FROM unnest($4::varchar[]) d))
 `
 
-type ListRecordsByBoxFilterPaginatedParams struct {
+type RemoveRecordTagsParams struct {
+	Column1   []string  `json:"column_1"`
 	BoxID     uuid.UUID `json:"box_id"`
 	Container string    `json:"container"`
-	Column3   []string  `json:"column_3"`
-	Data      string    `json:"data"`
-	Limit     int32     `json:"limit"`
-	Offset    int32     `json:"offset"`
+	Column4   []string  `json:"column_4"`
 }
 
-func (q *Queries) ListRecordsByBoxFilterPaginated(ctx context.Context, arg ListRecordsByBoxFilterPaginatedParams) ([]Record, error) {
-	rows, err := q.db.Query(ctx, listRecordsByBoxFilterPaginated,
+func (q *Queries) RemoveRecordTags(ctx context.Context, arg RemoveRecordTagsParams) error {
+	_, err := q.db.Exec(ctx, removeRecordTags,
+		arg.Column1,
 		arg.BoxID,
 		arg.Container,
-		arg.Column3,
-		arg.Data,
-		arg.Limit,
-		arg.Offset,
+		arg.Column4,
 	)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	items := []Record{}
-	for rows.Next() {
-		var i Record
-		if err := rows.Scan(
-			&i.Data,
-			&i.Tags,
-			&i.BoxID,
-			&i.Container,
-			&i.CreatedAt,
-		); err != nil {
-			return nil, err
-		}
-		items = append(items, i)
-	}
-	if err := rows.Err(); err != nil {
-		return nil, err
-	}
-	return items, nil
+	return err
 }
 
 const updateRecordTags = `-- name: UpdateRecordTags :exec
 UPDATE records SET
     tags = $1
 WHERE
-    box_id = $2 AND container = $3 AND data = ANY($4::varchar[])
+    box_id = $2 AND container = $3 AND data_hash = ANY(ARRAY(SELECT record_hash(d) FROM unnest($4::varchar[]) d))
 `
 
 type UpdateRecordTagsParams struct {
@@ -5,12 +5,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"hntr/normalize"
+	"hntr/recordtype"
+	"hntr/scope"
 	"io"
 	"log"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgconn"
+	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 )
@@ -55,76 +60,697 @@ func SetupRepository(dbUrl string, logQueries bool) (*Queries, *pgxpool.Pool, er
 	return New(pgxPool), pgxPool, nil
 }
 
-func RecordsBatchInsert(ctx context.Context, dbPool *pgxpool.Pool, reader io.Reader, boxId uuid.UUID, container string, tags []string, quotaRemaining int64, updateDuplicate bool) int64 {
-	var added int64
-	batch := &pgx.Batch{}
-
-	scanner := bufio.NewScanner(reader)
-
-	for scanner.Scan() {
-
-		line := strings.TrimSpace(scanner.Text())
-
-		if added > quotaRemaining {
-			break
-		}
-
-		if updateDuplicate {
-			batch.Queue(
-				`INSERT INTO 
-                records (box_id, container, data, tags)
-            VALUES 
-                ($1, $2, $3, $4)
-			ON CONFLICT (box_id, container, data) DO UPDATE
-			SET tags = excluded.tags
-            WHERE records.tags != excluded.tags`,
-				boxId,
-				container,
-				line,
-				tags,
-			)
-		} else {
-			batch.Queue(
-				`INSERT INTO 
-                records (box_id, container, data, tags)
-            VALUES 
-                ($1, $2, $3, $4)
-			ON CONFLICT (box_id, container, data) DO NOTHING`,
-				boxId,
-				container,
-				line,
-				tags,
-			)
-		}
-		added++
-	}
-
-	br := dbPool.SendBatch(ctx, batch)
-
-	var affected int64
-	var i int64
-	for i = 0; i < added; i++ {
-		ct, err := br.Exec()
-		if err != nil {
-			var pgErr *pgconn.PgError
-			if errors.As(err, &pgErr) {
-				if pgErr.Code != "23505" {
-					log.Printf("unable to exec batch insert: %v", pgErr)
-				}
-			}
+// RecordInput is a single record read from an ingest stream.
+type RecordInput struct {
+	Data       string                 `json:"data"`
+	Tags       []string               `json:"tags"`
+	Attributes map[string]interface{} `json:"attrs"`
+}
+
+// RecordReader provides records for RecordsBatchInsert. It is modeled after
+// bufio.Scanner: Scan advances to the next record, which is then available
+// via Record.
+type RecordReader interface {
+	Scan() bool
+	Record() RecordInput
+}
+
+// RecordDataMax is the maximum length of the data of a record in characters.
+const RecordDataMax = 8192
+
+type lineReader struct {
+	reader  *bufio.Reader
+	current string
+}
+
+// NewLineReader returns a RecordReader treating every line as a record.
+// Lines too long for any record are cut off, so they are reported as too
+// long by RecordsBatchInsert without holding them in memory.
+func NewLineReader(reader io.Reader) RecordReader {
+	// a character takes up to four bytes
+	return &lineReader{reader: bufio.NewReaderSize(reader, 4*RecordDataMax+2)}
+}
+
+func (r *lineReader) Scan() bool {
+	line, err := r.reader.ReadSlice('\n')
+	if len(line) == 0 && err != nil {
+		return false
+	}
+
+	r.current = strings.TrimRight(string(line), "\r\n")
+
+	for err == bufio.ErrBufferFull {
+		_, err = r.reader.ReadSlice('\n')
+	}
+
+	return true
+}
+
+func (r *lineReader) Record() RecordInput {
+	return RecordInput{Data: r.current}
+}
+
+// TagsMax is the maximum number of tags of a record.
+const TagsMax = 10
+
+// MergeTags returns the union of both tag lists, keeping the order in which
+// tags appear first.
+func MergeTags(tags []string, other []string) []string {
+	merged := make([]string, 0, len(tags)+len(other))
+	seen := make(map[string]bool)
+
+	for _, t := range append(append([]string{}, tags...), other...) {
+		if t == "" || seen[t] {
+			continue
 		}
+		seen[t] = true
+		merged = append(merged, t)
+	}
 
-		affected += ct.RowsAffected()
+	return merged
+}
+
+// mergeAttributes returns a new map holding all attributes, values in other
+// take precedence.
+func mergeAttributes(attributes map[string]interface{}, other map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(attributes)+len(other))
+
+	for k, v := range attributes {
+		merged[k] = v
 	}
+	for k, v := range other {
+		merged[k] = v
+	}
+
+	return merged
+}
+
+// DuplicateMode defines how RecordsBatchInsert handles records which already
+// exist in a container.
+type DuplicateMode int
+
+const (
+	// DuplicateIgnore keeps the existing record untouched.
+	DuplicateIgnore DuplicateMode = iota
+	// DuplicateReplace replaces the tags of the existing record.
+	DuplicateReplace
+	// DuplicateMerge adds new tags to the existing ones.
+	DuplicateMerge
+)
+
+// insertChunkSize is the number of records copied into the staging table at
+// once.
+const insertChunkSize = 5000
+
+// createStaging creates the table all records are collected in before they
+// are merged, and createChunk the one records are copied into on their way.
+// Both live as long as the connection, so the records inserted can still be
+// read once they are committed.
+const createStaging = `CREATE TEMPORARY TABLE record_staging (
+    position    BIGINT NOT NULL,
+    data        VARCHAR(8192) NOT NULL,
+    tags        VARCHAR(50)[],
+    attributes  JSONB NOT NULL,
+    normalized  BOOLEAN NOT NULL,
+    seen        INT NOT NULL,
+    merged      INT NOT NULL,
+    inserted    BOOLEAN NOT NULL DEFAULT FALSE,
+    data_hash   bytea GENERATED ALWAYS AS (record_hash(data)) STORED UNIQUE
+)`
+
+const createChunk = `CREATE TEMPORARY TABLE record_chunk (
+    position    BIGINT NOT NULL,
+    data        VARCHAR(8192) NOT NULL,
+    tags        VARCHAR(50)[],
+    attributes  JSONB NOT NULL,
+    normalized  BOOLEAN NOT NULL,
+    seen        INT NOT NULL,
+    merged      INT NOT NULL
+)`
+
+const dropStaging = `DROP TABLE IF EXISTS record_staging, record_chunk`
+
+// chunkColumns are the columns of record_chunk in the order of the copied
+// rows.
+var chunkColumns = []string{"position", "data", "tags", "attributes", "normalized", "seen", "merged"}
+
+// stageChunk moves the copied records into the staging table. A record
+// occurring in an earlier chunk already is folded into it, like a duplicate
+// record is by mergeRecords, using the update from stagingUpdates.
+const stageChunk = `INSERT INTO
+    record_staging (position, data, tags, attributes, normalized, seen, merged)
+SELECT position, data, tags, attributes, normalized, seen, merged FROM record_chunk
+ON CONFLICT (data_hash) DO UPDATE
+SET seen = record_staging.seen + excluded.seen,
+    merged = record_staging.merged + excluded.merged + excluded.normalized::int%s`
+
+var stagingUpdates = map[DuplicateMode]string{
+	DuplicateIgnore: "",
+	DuplicateReplace: `,
+    tags = excluded.tags,
+    attributes = record_staging.attributes || excluded.attributes`,
+	DuplicateMerge: fmt.Sprintf(`,
+    tags = (COALESCE(record_staging.tags, '{}') || ARRAY(
+        SELECT t FROM unnest(excluded.tags) t WHERE NOT t = ANY(COALESCE(record_staging.tags, '{}'))
+    ))[1:%d],
+    attributes = record_staging.attributes || excluded.attributes`, TagsMax),
+}
+
+// countNew counts the staged records which do not exist yet.
+const countNew = `SELECT count(*) FROM record_staging s
+WHERE NOT EXISTS (
+    SELECT 1 FROM records r WHERE r.box_id = $1 AND r.container = $2 AND r.data_hash = s.data_hash
+)`
+
+// mergeRecords inserts all staged records and marks the ones which did not
+// exist before. On conflict the record is marked as seen again and the
+// duplicate mode specific update from duplicateUpdates is applied. It returns
+// the number of inserted records, of all staged lines, of existing records
+// which changed and of lines which were merged by normalization.
+const mergeRecords = `WITH existing AS (
+    SELECT data_hash, tags, attributes FROM records
+    WHERE box_id = $1 AND container = $2 AND data_hash IN (SELECT data_hash FROM record_staging)
+), upserted AS (
+    INSERT INTO
+        records (box_id, container, data, tags, attributes, seen_count)
+    SELECT $1::uuid, $2::varchar, data, tags, attributes, seen FROM record_staging
+    ON CONFLICT (box_id, container, data_hash) DO UPDATE
+    SET last_seen_at = NOW(), seen_count = records.seen_count + excluded.seen_count%s
+    RETURNING data_hash, tags, attributes
+), marked AS (
+    UPDATE record_staging SET inserted = TRUE
+    WHERE data_hash NOT IN (SELECT data_hash FROM existing)
+)
+SELECT
+    count(*) FILTER (WHERE e.data_hash IS NULL),
+    COALESCE(sum(s.seen), 0),
+    count(*) FILTER (WHERE e.data_hash IS NOT NULL AND NOT (e.tags IS NOT DISTINCT FROM u.tags AND e.attributes = u.attributes)),
+    COALESCE(sum(s.merged), 0) + count(*) FILTER (WHERE e.data_hash IS NOT NULL AND s.normalized)
+FROM upserted u
+JOIN record_staging s ON s.data_hash = u.data_hash
+LEFT JOIN existing e ON e.data_hash = u.data_hash`
+
+// listInserted returns the records marked by mergeRecords in the order they
+// were read.
+const listInserted = `SELECT data FROM record_staging WHERE inserted ORDER BY position`
+
+var duplicateUpdates = map[DuplicateMode]string{
+	DuplicateIgnore: "",
+	DuplicateReplace: `,
+        tags = excluded.tags,
+        attributes = records.attributes || excluded.attributes`,
+	DuplicateMerge: fmt.Sprintf(`,
+        tags = (COALESCE(records.tags, '{}') || ARRAY(
+            SELECT t FROM unnest(excluded.tags) t WHERE NOT t = ANY(COALESCE(records.tags, '{}'))
+        ))[1:%d],
+        attributes = records.attributes || excluded.attributes`, TagsMax),
+}
+
+// lockBox serializes changes counted against the quotas of a box.
+const lockBox = `SELECT 1 FROM boxes WHERE id = $1 FOR NO KEY UPDATE`
+
+// linkRecords links all staged records to their parent, unless the parent was
+// removed in the meantime. A record is not linked to itself.
+const linkRecords = `INSERT INTO record_edges
+    (box_id, source_container, source_data, target_container, target_data, automation_id, source_hash, target_hash)
+SELECT $1::uuid, $2::varchar, $3::varchar, $4::varchar, s.data, $5::uuid, record_hash($3), record_hash(s.data)
+FROM record_staging s
+WHERE ($2 <> $4 OR s.data <> $3)
+AND EXISTS (SELECT 1 FROM records WHERE box_id = $1 AND container = $2 AND data_hash = record_hash($3))
+ON CONFLICT DO NOTHING`
+
+// addSources stores where the staged records came from. A record is stored
+// once per automation event and once for all uploads.
+const addSources = `INSERT INTO record_sources
+    (box_id, container, data, kind, event_id, automation_id, command, input, data_hash)
+SELECT $1::uuid, $2::varchar, s.data, $3::varchar, $4::uuid, $5::uuid, $6::text, $7::text, record_hash(s.data)
+FROM record_staging s
+ON CONFLICT (box_id, container, data_hash, COALESCE(event_id, '00000000-0000-0000-0000-000000000000')) DO NOTHING`
+
+const (
+	SourceUpload     = "upload"
+	SourceAutomation = "automation"
+)
+
+// Source describes where records come from. For automations the command and
+// input are kept as well, as the automation may be changed or removed later.
+type Source struct {
+	Kind         string
+	EventID      uuid.UUID
+	AutomationID uuid.UUID
+	Command      string
+	Input        string
+}
+
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
+
+// Parent is the record new records were derived from, e.g. the input of the
+// automation returning them.
+type Parent struct {
+	Container    string
+	Data         string
+	AutomationID uuid.UUID
+}
+
+// InsertOptions configures how RecordsBatchInsert stores records.
+type InsertOptions struct {
+	BoxID     uuid.UUID
+	Container string
+
+	// Tags and Attributes are added to every record in addition to the ones
+	// a record carries itself.
+	Tags       []string
+	Attributes map[string]interface{}
+
+	// Quota is the maximum number of records of the box, ContainerQuota
+	// the one of the container. Zero means unlimited.
+	Quota          int64
+	ContainerQuota int64
+
+	DuplicateMode DuplicateMode
+
+	// Normalizers are applied to the data of every record after surrounding
+	// whitespace is removed.
+	Normalizers normalize.Pipeline
+
+	// Type is checked after normalization, records of another type are
+	// rejected.
+	Type string
+
+	// Scope, if set, tags records as in or out of scope, or drops out of
+	// scope records.
+	Scope *scope.Scope
+
+	// Parent, if set, is linked to every record.
+	Parent *Parent
+
+	// Source, if set, is stored for every record.
+	Source *Source
+
+	// OnCommit, if set, is called once all records are stored, before
+	// OnInserted.
+	OnCommit func(result InsertResult)
+
+	// OnInserted, if set, is called with the data of every record which did
+	// not exist before, once all records are stored.
+	OnInserted func(data string)
+}
+
+// InsertSamplesMax is the number of skipped lines reported in an
+// InsertResult.
+const InsertSamplesMax = 10
+
+// sampleLengthMax cuts off long lines in samples, counted in runes.
+const sampleLengthMax = 200
+
+// Skipped is a line which was not stored, together with the reason.
+type Skipped struct {
+	Line   string `json:"line"`
+	Reason string `json:"reason"`
+}
+
+// InsertResult reports what happened to the lines passed to
+// RecordsBatchInsert.
+type InsertResult struct {
+	// Received is the number of lines read, including empty ones.
+	Received int64 `json:"received"`
+	// Empty is the number of empty lines, which are skipped.
+	Empty int64 `json:"empty"`
+	// Inserted is the number of new records.
+	Inserted int64 `json:"inserted"`
+	// Duplicates is the number of records which existed before.
+	Duplicates int64 `json:"duplicates"`
+	// TagsUpdated is the number of existing records whose tags or
+	// attributes changed.
+	TagsUpdated int64 `json:"tags_updated"`
+	// Affected is the number of inserted or changed records.
+	Affected int64 `json:"changed"`
+	// Dropped is the number of records dropped by a normalizer.
+	Dropped int64 `json:"dropped"`
+	// Merged is the number of records which only became duplicates of an
+	// existing record by normalization.
+	Merged int64 `json:"merged"`
+	// Rejected is the number of records not matching the container type.
+	Rejected int64 `json:"rejected"`
+	// TooLong is the number of records exceeding RecordDataMax.
+	TooLong int64 `json:"too_long"`
+	// OutOfScope is the number of out of scope records, which were either
+	// tagged or dropped.
+	OutOfScope int64 `json:"out_of_scope"`
+	// Failed is the number of records the database refused to store.
+	Failed int64 `json:"failed"`
+	// Usage is the number of records of the box after the insert.
+	Usage Usage `json:"usage"`
+	// Samples holds the first skipped lines with the reason they were
+	// skipped.
+	Samples []Skipped `json:"samples"`
+}
+
+func (r *InsertResult) skip(line string, reason string) {
+	if len(r.Samples) >= InsertSamplesMax {
+		return
+	}
+
+	if runes := []rune(line); len(runes) > sampleLengthMax {
+		line = string(runes[:sampleLengthMax]) + "..."
+	}
+	r.Samples = append(r.Samples, Skipped{Line: line, Reason: reason})
+}
+
+// JSONB encodes the result for storing it along with automation events.
+func (r InsertResult) JSONB() pgtype.JSONB {
+	var encoded pgtype.JSONB
+	if err := encoded.Set(r); err != nil {
+		return pgtype.JSONB{Status: pgtype.Null}
+	}
+	return encoded
+}
+
+// Usage is the number of records of a box after an insert.
+type Usage struct {
+	// Quota of zero means unlimited.
+	Quota int64 `json:"quota"`
+	Count int64 `json:"count"`
+}
+
+// Remaining returns the number of records which can still be added, or -1
+// if the quota is unlimited.
+func (u Usage) Remaining() int64 {
+	if u.Quota == 0 {
+		return -1
+	}
+	if u.Count > u.Quota {
+		return 0
+	}
+	return u.Quota - u.Count
+}
+
+// QuotaError is returned by RecordsBatchInsert if the records would exceed
+// the quota of the box or container. None of the records are stored then.
+type QuotaError struct {
+	// Container is empty if the quota of the box is exceeded.
+	Container string
+	Quota     int64
+	// Count is the number of records including the rejected ones.
+	Count int64
+}
 
-	if err := br.Close(); err != nil {
+func (e *QuotaError) Error() string {
+	if e.Container != "" {
+		return fmt.Sprintf("quota of container %s exceeded: %d of %d records", e.Container, e.Count, e.Quota)
+	}
+	return fmt.Sprintf("quota exceeded: %d of %d records", e.Count, e.Quota)
+}
+
+// RecordsBatchInsert inserts all records provided by reader. Records which
+// already exist are marked as seen again. The records are copied into a
+// staging table in chunks, so memory does not grow with the number of records,
+// and merged all at once, if any quota would be exceeded a *QuotaError is
+// returned and none are stored. The box is only locked while merging, not
+// while reading. OnCommit and OnInserted are only called after the records
+// were stored.
+func RecordsBatchInsert(ctx context.Context, dbPool *pgxpool.Pool, reader RecordReader, opts InsertOptions) (InsertResult, error) {
+	result := InsertResult{Samples: make([]Skipped, 0)}
+	result.Usage.Quota = opts.Quota
+
+	conn, err := dbPool.Acquire(ctx)
+	if err != nil {
+		return result, err
+	}
+	defer conn.Release()
+
+	// the staging tables have to be gone before the connection is reused
+	defer func() {
+		if _, err := conn.Exec(context.Background(), dropStaging); err != nil {
+			log.Printf("dropping staging tables failed: %v", err)
+			conn.Conn().Close(context.Background())
+		}
+	}()
+
+	if _, err := conn.Exec(ctx, createStaging); err != nil {
+		return result, err
+	}
+	if _, err := conn.Exec(ctx, createChunk); err != nil {
+		return result, err
+	}
+
+	var staged int64
+
+	// every record is passed on once per chunk, repeated ones are folded
+	// into the first occurrence
+	rows := make([][]interface{}, 0, insertChunkSize)
+	chunk := make(map[string]int, insertChunkSize)
+
+	flush := func() error {
+		if len(rows) == 0 {
+			return nil
+		}
+
+		if _, err := conn.Exec(ctx, "TRUNCATE record_chunk"); err != nil {
+			return err
+		}
+
+		if _, err := conn.CopyFrom(
+			ctx,
+			pgx.Identifier{"record_chunk"},
+			chunkColumns,
+			pgx.CopyFromRows(rows),
+		); err != nil {
+			return err
+		}
+
+		if _, err := conn.Exec(ctx, fmt.Sprintf(stageChunk, stagingUpdates[opts.DuplicateMode])); err != nil {
+			return err
+		}
+
+		rows = rows[:0]
+		chunk = make(map[string]int, insertChunkSize)
+		return nil
+	}
+
+	// fail reports all lines as failed, as none of them are stored
+	fail := func(err error) (InsertResult, error) {
 		var pgErr *pgconn.PgError
 		if errors.As(err, &pgErr) {
-			if pgErr.Code != "23505" {
-				log.Printf("unable to exec batch insert: %v", pgErr)
+			log.Printf("unable to insert records: %v", pgErr)
+		}
+		result.Failed = staged
+		return result, fmt.Errorf("storing records failed: %w", err)
+	}
+
+	for reader.Scan() {
+		result.Received++
+
+		record := reader.Record()
+		line := strings.TrimSpace(record.Data)
+
+		if line == "" {
+			result.Empty++
+			continue
+		}
+
+		normalizedLine, ok := opts.Normalizers.Apply(line)
+		if !ok {
+			result.Dropped++
+			result.skip(line, "dropped by normalizer")
+			continue
+		}
+
+		if utf8.RuneCountInString(normalizedLine) > RecordDataMax {
+			result.TooLong++
+			result.skip(line, fmt.Sprintf("longer than %d characters", RecordDataMax))
+			continue
+		}
+
+		if !recordtype.Valid(opts.Type, normalizedLine) {
+			result.Rejected++
+			result.skip(line, fmt.Sprintf("not of type %s", opts.Type))
+			continue
+		}
+
+		// mergeAttributes never returns nil, which would be stored as json null
+		recordTags := MergeTags(opts.Tags, record.Tags)
+		recordAttributes := mergeAttributes(opts.Attributes, record.Attributes)
+
+		if opts.Scope != nil && Scoped(opts.Type, normalizedLine) {
+			scopeTag := scope.TagIn
+			if !opts.Scope.Contains(normalizedLine) {
+				result.OutOfScope++
+				if opts.Scope.Mode() == scope.ModeDrop {
+					result.skip(line, "out of scope")
+					continue
+				}
+				scopeTag = scope.TagOut
+			}
+
+			recordTags = MergeTags(recordTags, []string{scopeTag})
+		}
+
+		normalized := normalizedLine != line
+		staged++
+
+		// a repeated record is folded like it was sent again
+		if i, ok := chunk[normalizedLine]; ok {
+			row := rows[i]
+			row[5] = row[5].(int32) + 1
+			if normalized {
+				row[6] = row[6].(int32) + 1
+			}
+
+			switch opts.DuplicateMode {
+			case DuplicateReplace:
+				row[2] = recordTags
+				row[3] = mergeAttributes(row[3].(map[string]interface{}), recordAttributes)
+			case DuplicateMerge:
+				merged := MergeTags(row[2].([]string), recordTags)
+				if len(merged) > TagsMax {
+					merged = merged[:TagsMax]
+				}
+				row[2] = merged
+				row[3] = mergeAttributes(row[3].(map[string]interface{}), recordAttributes)
+			}
+			continue
+		}
+
+		if len(rows) == insertChunkSize {
+			if err := flush(); err != nil {
+				return fail(err)
 			}
 		}
+
+		chunk[normalizedLine] = len(rows)
+		rows = append(rows, []interface{}{
+			staged,
+			normalizedLine,
+			recordTags,
+			recordAttributes,
+			normalized,
+			int32(1),
+			int32(0),
+		})
+	}
+
+	if err := flush(); err != nil {
+		return fail(err)
+	}
+
+	// the box is locked until the records are committed, so that concurrent
+	// inserts can not exceed the quota together
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return fail(err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, lockBox, opts.BoxID); err != nil {
+		return fail(err)
+	}
+
+	q := New(tx)
+
+	if result.Usage.Count, err = q.CountRecordsByBox(ctx, opts.BoxID); err != nil {
+		return fail(err)
+	}
+
+	var newCount int64
+	if err := tx.QueryRow(ctx, countNew, opts.BoxID, opts.Container).Scan(&newCount); err != nil {
+		return fail(err)
+	}
+
+	if opts.Quota > 0 && result.Usage.Count+newCount > opts.Quota {
+		return result, &QuotaError{Quota: opts.Quota, Count: result.Usage.Count + newCount}
+	}
+
+	if opts.ContainerQuota > 0 {
+		containerCount, err := q.CountRecordsByContainer(ctx, CountRecordsByContainerParams{
+			BoxID:     opts.BoxID,
+			Container: opts.Container,
+		})
+		if err != nil {
+			return fail(err)
+		}
+
+		if containerCount+newCount > opts.ContainerQuota {
+			return result, &QuotaError{Container: opts.Container, Quota: opts.ContainerQuota, Count: containerCount + newCount}
+		}
+	}
+
+	var inserted, seen, tagsUpdated, merged int64
+	if err := tx.QueryRow(
+		ctx,
+		fmt.Sprintf(mergeRecords, duplicateUpdates[opts.DuplicateMode]),
+		opts.BoxID,
+		opts.Container,
+	).Scan(&inserted, &seen, &tagsUpdated, &merged); err != nil {
+		return fail(err)
+	}
+
+	if opts.Parent != nil {
+		if _, err := tx.Exec(
+			ctx,
+			linkRecords,
+			opts.BoxID,
+			opts.Parent.Container,
+			opts.Parent.Data,
+			opts.Container,
+			opts.Parent.AutomationID,
+		); err != nil {
+			return fail(err)
+		}
+	}
+
+	if opts.Source != nil {
+		if _, err := tx.Exec(
+			ctx,
+			addSources,
+			opts.BoxID,
+			opts.Container,
+			opts.Source.Kind,
+			nullUUID(opts.Source.EventID),
+			nullUUID(opts.Source.AutomationID),
+			opts.Source.Command,
+			opts.Source.Input,
+		); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fail(err)
+	}
+
+	result.Inserted = inserted
+	result.Duplicates = seen - inserted
+	result.TagsUpdated = tagsUpdated
+	result.Affected = inserted + tagsUpdated
+	result.Merged = merged
+	result.Usage.Count += inserted
+
+	if opts.OnCommit != nil {
+		opts.OnCommit(result)
+	}
+
+	if opts.OnInserted == nil {
+		return result, nil
+	}
+
+	// the inserted records are read back from the staging table, so they
+	// are not held in memory
+	insertedRows, err := conn.Query(ctx, listInserted)
+	if err != nil {
+		return result, err
+	}
+	defer insertedRows.Close()
+
+	for insertedRows.Next() {
+		var data string
+		if err := insertedRows.Scan(&data); err != nil {
+			return result, err
+		}
+		opts.OnInserted(data)
 	}
 
-	return affected
+	return result, insertedRows.Err()
 }
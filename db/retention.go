@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// Expired is the number of records deleted from a container by a retention
+// rule.
+type Expired struct {
+	BoxID     uuid.UUID
+	Container string
+	Rule      RetentionRule
+	Deleted   int64
+}
+
+// DeleteExpired applies the retention rules of all containers of all boxes.
+// It returns the number of records deleted by every rule applied. A failing
+// rule is logged and skipped, so that it does not keep the others from
+// expiring records.
+func (q *Queries) DeleteExpired(ctx context.Context) ([]Expired, error) {
+	boxes, err := q.ListBoxes(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := make([]Expired, 0)
+
+	for _, box := range boxes {
+		for container, settings := range box.SettingsMap() {
+			for _, rule := range settings.Retention {
+				deleted, err := q.DeleteExpiredRecords(ctx, DeleteExpiredRecordsParams{
+					BoxID:     box.ID,
+					Container: container,
+					Days:      rule.Days,
+					Tag:       rule.Tag,
+				})
+				if err != nil {
+					log.Printf("deleting expired records of box %v container %s failed: %v", box.ID, container, err)
+					continue
+				}
+
+				expired = append(expired, Expired{
+					BoxID:     box.ID,
+					Container: container,
+					Rule:      rule,
+					Deleted:   deleted,
+				})
+			}
+		}
+	}
+
+	return expired, nil
+}
@@ -0,0 +1,245 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hntr/search"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+)
+
+// The queries in this file are built from search terms at runtime and can
+// therefore not be generated by sqlc.
+
+const recordColumns = "data, tags, box_id, container, created_at, attributes, last_seen_at, seen_count"
+
+func scanRecord(row pgx.Row) (Record, error) {
+	var i Record
+	err := row.Scan(
+		&i.Data,
+		&i.Tags,
+		&i.BoxID,
+		&i.Container,
+		&i.CreatedAt,
+		&i.Attributes,
+		&i.LastSeenAt,
+		&i.SeenCount,
+	)
+	return i, err
+}
+
+// RecordCursor points to a record in the order records are listed in. It is
+// used for keyset pagination, which unlike an offset is not affected by
+// records being added while paging.
+type RecordCursor struct {
+	CreatedAt time.Time `json:"c"`
+	Data      string    `json:"d"`
+}
+
+// NewRecordCursor returns a cursor pointing to record.
+func NewRecordCursor(record Record) RecordCursor {
+	return RecordCursor{CreatedAt: record.CreatedAt, Data: record.Data}
+}
+
+// Encode returns the cursor as an opaque string.
+func (c RecordCursor) Encode() string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// DecodeRecordCursor parses a cursor created by Encode.
+func DecodeRecordCursor(encoded string) (RecordCursor, error) {
+	var c RecordCursor
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return c, fmt.Errorf("invalid cursor")
+	}
+
+	return c, nil
+}
+
+type ListRecordsBySearchParams struct {
+	BoxID     uuid.UUID
+	Container string
+	Query     *search.Query
+	// After, if set, only returns records following the cursor and takes
+	// precedence over Offset.
+	After *RecordCursor
+	// Limit of zero returns all matching records.
+	Limit  int32
+	Offset int32
+}
+
+func (arg ListRecordsBySearchParams) sql() (string, []interface{}) {
+	where, args := arg.Query.Where(2)
+	args = append([]interface{}{arg.BoxID, arg.Container}, args...)
+
+	if arg.After != nil {
+		args = append(args, arg.After.CreatedAt, arg.After.Data)
+		where += fmt.Sprintf(" AND (created_at < $%[1]d OR (created_at = $%[1]d AND data > $%[2]d))", len(args)-1, len(args))
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM records WHERE
+    box_id = $1 AND
+    container = $2 AND
+    %s
+ORDER BY created_at DESC, data`, recordColumns, where)
+
+	if arg.Limit > 0 && arg.After != nil {
+		args = append(args, arg.Limit)
+		query += fmt.Sprintf("\nLIMIT $%d", len(args))
+	} else if arg.Limit > 0 {
+		args = append(args, arg.Limit, arg.Offset)
+		query += fmt.Sprintf("\nLIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	return query, args
+}
+
+func (q *Queries) ListRecordsBySearch(ctx context.Context, arg ListRecordsBySearchParams) ([]Record, error) {
+	items := []Record{}
+	err := q.StreamRecordsBySearch(ctx, arg, func(i Record) error {
+		items = append(items, i)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// StreamRecordsBySearch calls fn for every matching record while the rows are
+// read from the database, so the result set is never held in memory. An error
+// returned by fn stops the iteration and is returned.
+func (q *Queries) StreamRecordsBySearch(ctx context.Context, arg ListRecordsBySearchParams, fn func(Record) error) error {
+	query, args := arg.sql()
+
+	rows, err := q.db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		i, err := scanRecord(rows)
+		if err != nil {
+			return err
+		}
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type CountRecordsBySearchParams struct {
+	BoxID     uuid.UUID
+	Container string
+	Query     *search.Query
+}
+
+func (q *Queries) CountRecordsBySearch(ctx context.Context, arg CountRecordsBySearchParams) (int64, error) {
+	where, args := arg.Query.Where(2)
+
+	query := fmt.Sprintf(`SELECT count(*) FROM records WHERE
+    box_id = $1 AND
+    container = $2 AND
+    %s`, where)
+
+	args = append([]interface{}{arg.BoxID, arg.Container}, args...)
+
+	row := q.db.QueryRow(ctx, query, args...)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+// Actions of BulkRecordsBySearch.
+const (
+	BulkAddTags     = "add"
+	BulkRemoveTags  = "remove"
+	BulkReplaceTags = "replace"
+	BulkDelete      = "delete"
+)
+
+type BulkRecordsBySearchParams struct {
+	BoxID     uuid.UUID
+	Container string
+	Query     *search.Query
+	Action    string
+	Tags      []string
+	// DryRun only counts the records the action would affect.
+	DryRun bool
+}
+
+// ErrTooManyTags is returned by BulkRecordsBySearch if adding tags would
+// exceed TagsMax for any record. No record is changed then.
+var ErrTooManyTags = errors.New("too many tags")
+
+// BulkRecordsBySearch applies an action to all matching records in a single
+// statement and returns the number of affected records. Records which would
+// not change, e.g. as they already carry all added tags, are not counted.
+func (q *Queries) BulkRecordsBySearch(ctx context.Context, arg BulkRecordsBySearchParams) (int64, error) {
+	where, args := arg.Query.Where(2)
+	args = append([]interface{}{arg.BoxID, arg.Container}, args...)
+	where = "box_id = $1 AND container = $2 AND " + where
+
+	var statement string
+	switch arg.Action {
+	case BulkAddTags:
+		args = append(args, arg.Tags)
+		added := fmt.Sprintf(`COALESCE(tags, '{}') || ARRAY(
+        SELECT t FROM unnest($%[1]d::varchar[]) t WHERE NOT t = ANY(COALESCE(tags, '{}'))
+    )`, len(args))
+		statement = "UPDATE records SET\n    tags = " + added
+		where += fmt.Sprintf(" AND NOT COALESCE(tags @> $%d::varchar[], FALSE)", len(args))
+
+		var exceeding int64
+		if err := q.db.QueryRow(
+			ctx,
+			fmt.Sprintf("SELECT count(*) FROM records WHERE %s AND cardinality(%s) > %d", where, added, TagsMax),
+			args...,
+		).Scan(&exceeding); err != nil {
+			return 0, err
+		}
+
+		if exceeding > 0 {
+			return 0, fmt.Errorf("%w: %d records would have more than %d", ErrTooManyTags, exceeding, TagsMax)
+		}
+	case BulkRemoveTags:
+		args = append(args, arg.Tags)
+		statement = fmt.Sprintf(`UPDATE records SET
+    tags = ARRAY(SELECT t FROM unnest(tags) t WHERE NOT t = ANY($%d::varchar[]))`, len(args))
+		where += fmt.Sprintf(" AND tags && $%d::varchar[]", len(args))
+	case BulkReplaceTags:
+		args = append(args, arg.Tags)
+		statement = fmt.Sprintf(`UPDATE records SET
+    tags = $%d`, len(args))
+		where += fmt.Sprintf(" AND NOT (COALESCE(tags, '{}') @> $%[1]d::varchar[] AND COALESCE(tags, '{}') <@ $%[1]d::varchar[])", len(args))
+	case BulkDelete:
+		statement = "DELETE FROM records"
+	default:
+		return 0, fmt.Errorf("unknown action %q", arg.Action)
+	}
+
+	if arg.DryRun {
+		var count int64
+		err := q.db.QueryRow(ctx, "SELECT count(*) FROM records WHERE "+where, args...).Scan(&count)
+		return count, err
+	}
+
+	tag, err := q.db.Exec(ctx, statement+"\nWHERE "+where, args...)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
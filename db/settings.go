@@ -0,0 +1,196 @@
+package db
+
+import (
+	"encoding/json"
+	"hntr/normalize"
+	"hntr/recordtype"
+	"hntr/scope"
+	"net"
+
+	"github.com/jackc/pgtype"
+)
+
+// ContainerSettings configures how records of a single container are
+// ingested. The settings of all containers are stored as json object keyed by
+// container in the container_settings column of a box.
+type ContainerSettings struct {
+	// Type of the records, see package recordtype. Records not matching the
+	// type are rejected.
+	Type string `json:"type,omitempty" validate:"recordtype"`
+
+	// Normalizers are applied to the data of every record, see package
+	// normalize for the available names.
+	Normalizers []string `json:"normalizers"`
+
+	// Quota limits the number of records of the container in addition to
+	// the quota of the box. Zero means no limit.
+	Quota int64 `json:"quota,omitempty" validate:"min=0"`
+
+	// Retention rules delete records of the container once they expire.
+	Retention []RetentionRule `json:"retention,omitempty" validate:"max=10,dive"`
+}
+
+// RetentionRule expires records which were not seen for Days. If Tag is set,
+// only records carrying the tag expire.
+type RetentionRule struct {
+	Days int32  `json:"days" validate:"min=1,max=3650"`
+	Tag  string `json:"tag,omitempty" validate:"max=50"`
+}
+
+// Quotas limit the number of records and scheduled automation events of a
+// box. The quotas of an instance apply to boxes which have none of their own.
+type Quotas struct {
+	Records int64
+	Events  int64
+}
+
+// Quotas returns the quotas of the box, using defaults for the ones not set.
+func (b Box) Quotas(defaults Quotas) Quotas {
+	quotas := defaults
+
+	if b.RecordsQuota.Valid {
+		quotas.Records = b.RecordsQuota.Int64
+	}
+
+	if b.EventsQuota.Valid {
+		quotas.Events = b.EventsQuota.Int64
+	}
+
+	return quotas
+}
+
+// SettingsMap returns the settings of all containers of the box.
+func (b Box) SettingsMap() map[string]ContainerSettings {
+	settings := make(map[string]ContainerSettings)
+
+	if b.ContainerSettings.Status == pgtype.Present {
+		_ = json.Unmarshal(b.ContainerSettings.Bytes, &settings)
+	}
+
+	return settings
+}
+
+// Settings returns the settings of container, which are empty if none are
+// stored.
+func (b Box) Settings(container string) ContainerSettings {
+	return b.SettingsMap()[container]
+}
+
+// RenameRetentionTag returns the settings of all containers of the box with
+// tag renamed to newTag in their retention rules, and whether any rule
+// changed. An empty newTag removes the rules of tag, as they would expire
+// every record of the container otherwise.
+func (b Box) RenameRetentionTag(tag, newTag string) (map[string]ContainerSettings, bool) {
+	settings := b.SettingsMap()
+	changed := false
+
+	for container, s := range settings {
+		rules := make([]RetentionRule, 0, len(s.Retention))
+		for _, rule := range s.Retention {
+			if rule.Tag == tag {
+				changed = true
+				if newTag == "" {
+					continue
+				}
+				rule.Tag = newTag
+			}
+			rules = append(rules, rule)
+		}
+
+		s.Retention = rules
+		settings[container] = s
+	}
+
+	return settings, changed
+}
+
+// EncodeSettings encodes the settings of all containers for storage in a box.
+func EncodeSettings(settings map[string]ContainerSettings) (pgtype.JSONB, error) {
+	var encoded pgtype.JSONB
+	err := encoded.Set(settings)
+	return encoded, err
+}
+
+// Pipeline returns the configured normalizers.
+func (s ContainerSettings) Pipeline() (normalize.Pipeline, error) {
+	return normalize.New(s.Normalizers)
+}
+
+// Apply configures opts to ingest records according to the settings.
+func (s ContainerSettings) Apply(opts *InsertOptions) error {
+	normalizers, err := s.Pipeline()
+	if err != nil {
+		return err
+	}
+
+	opts.Normalizers = normalizers
+	opts.Type = s.Type
+	opts.ContainerQuota = s.Quota
+
+	return nil
+}
+
+// ScopeRules returns the scope rules of the box.
+func (b Box) ScopeRules() scope.Rules {
+	rules := scope.Rules{}
+
+	if b.Scope.Status == pgtype.Present {
+		_ = json.Unmarshal(b.Scope.Bytes, &rules)
+	}
+
+	return rules
+}
+
+// scopedTypes are the record types scope rules apply to. Records of other
+// types, e.g. free text events, are neither in nor out of scope.
+var scopedTypes = map[string]bool{
+	recordtype.Hostname: true,
+	recordtype.URL:      true,
+	recordtype.IP:       true,
+	recordtype.CIDR:     true,
+	recordtype.Email:    true,
+}
+
+// Scoped reports whether scope rules apply to data stored in a container of
+// type t. The type of data in containers without a type is detected, host and
+// port pairs count as their host.
+func Scoped(t string, data string) bool {
+	if t != "" {
+		return scopedTypes[t]
+	}
+
+	if host, _, err := net.SplitHostPort(data); err == nil {
+		data = host
+	}
+
+	return scopedTypes[recordtype.Detect(data)]
+}
+
+// ContainerScope returns the scope of the box for records of container. It is
+// nil if the box has no scope rules or the container is of a type scope rules
+// do not apply to. In containers without a type it only applies to records
+// which are Scoped.
+func (b Box) ContainerScope(container string) (*scope.Scope, error) {
+	if t := b.Settings(container).Type; t != "" && !scopedTypes[t] {
+		return nil, nil
+	}
+
+	return scope.New(b.ScopeRules())
+}
+
+// Apply configures opts to ingest records into container according to the
+// settings of the container and the scope of the box.
+func (b Box) Apply(container string, opts *InsertOptions) error {
+	if err := b.Settings(container).Apply(opts); err != nil {
+		return err
+	}
+
+	s, err := b.ContainerScope(container)
+	if err != nil {
+		return err
+	}
+
+	opts.Scope = s
+
+	return nil
+}
@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+)
+
+const (
+	SnapshotManual     = "manual"
+	SnapshotAutomation = "automation"
+)
+
+// ErrSnapshotsQuota is returned by TakeSnapshot if the snapshots of a box
+// would keep more records than its records quota.
+var ErrSnapshotsQuota = errors.New("snapshots quota exceeded")
+
+// TakeSnapshot stores the current records of a box as a snapshot and returns
+// it with the number of records copied. If keep is set, only the latest keep
+// snapshots of the same kind are kept. All snapshots of the box together can
+// keep at most quota records, a quota of zero means unlimited.
+func TakeSnapshot(ctx context.Context, dbPool *pgxpool.Pool, params CreateSnapshotParams, keep int32, quota int64) (Snapshot, int64, error) {
+	tx, err := dbPool.Begin(ctx)
+	if err != nil {
+		return Snapshot{}, 0, err
+	}
+	defer tx.Rollback(ctx)
+
+	// the box is locked until the snapshot is committed, so that concurrent
+	// snapshots can not exceed the quota together
+	if _, err := tx.Exec(ctx, lockBox, params.BoxID); err != nil {
+		return Snapshot{}, 0, err
+	}
+
+	q := New(tx)
+
+	// make room for the new snapshot first, so that the dropped ones no
+	// longer count against the quota
+	if keep > 0 {
+		if err := q.DeleteSnapshotsOld(ctx, DeleteSnapshotsOldParams{
+			BoxID: params.BoxID,
+			Kind:  params.Kind,
+			Limit: keep - 1,
+		}); err != nil {
+			return Snapshot{}, 0, err
+		}
+	}
+
+	if quota > 0 {
+		kept, err := q.CountSnapshotRecordsByBox(ctx, params.BoxID)
+		if err != nil {
+			return Snapshot{}, 0, err
+		}
+
+		count, err := q.CountRecordsByBox(ctx, params.BoxID)
+		if err != nil {
+			return Snapshot{}, 0, err
+		}
+
+		if kept+count > quota {
+			return Snapshot{}, 0, fmt.Errorf("%w: %d of %d records", ErrSnapshotsQuota, kept+count, quota)
+		}
+	}
+
+	snapshot, err := q.CreateSnapshot(ctx, params)
+	if err != nil {
+		return snapshot, 0, err
+	}
+
+	count, err := q.CopySnapshotRecords(ctx, CopySnapshotRecordsParams{
+		SnapshotID: snapshot.ID,
+		BoxID:      snapshot.BoxID,
+	})
+	if err != nil {
+		return snapshot, 0, err
+	}
+
+	return snapshot, count, tx.Commit(ctx)
+}
+
+// DiffEntry is a record which changed between two snapshots. Tags are the
+// current tags of the record, OldTags the ones it had before if they changed.
+type DiffEntry struct {
+	Data    string   `json:"data"`
+	Tags    []string `json:"tags"`
+	OldTags []string `json:"old_tags,omitempty"`
+}
+
+// ContainerDiff lists the records of a container which were added, removed or
+// got different tags.
+type ContainerDiff struct {
+	Added       []DiffEntry `json:"added"`
+	Removed     []DiffEntry `json:"removed"`
+	TagsChanged []DiffEntry `json:"tags_changed"`
+}
+
+// GroupDiff groups the changes returned by DiffSnapshot by container.
+func GroupDiff(rows []DiffSnapshotRow) map[string]*ContainerDiff {
+	diffs := make(map[string]*ContainerDiff)
+
+	for _, row := range rows {
+		diff, ok := diffs[row.Container]
+		if !ok {
+			diff = &ContainerDiff{
+				Added:       make([]DiffEntry, 0),
+				Removed:     make([]DiffEntry, 0),
+				TagsChanged: make([]DiffEntry, 0),
+			}
+			diffs[row.Container] = diff
+		}
+
+		switch row.Change {
+		case "added":
+			diff.Added = append(diff.Added, DiffEntry{Data: row.Data, Tags: row.NewTags})
+		case "removed":
+			diff.Removed = append(diff.Removed, DiffEntry{Data: row.Data, Tags: row.OldTags})
+		default:
+			diff.TagsChanged = append(diff.TagsChanged, DiffEntry{Data: row.Data, Tags: row.NewTags, OldTags: row.OldTags})
+		}
+	}
+
+	return diffs
+}
@@ -0,0 +1,127 @@
+// Package domain groups hostnames by their registrable domain, the apex
+// domain, e.g. example.co.uk for api.dev.example.co.uk. Registrable domains
+// are determined by the public suffix list embedded in
+// golang.org/x/net/publicsuffix.
+package domain
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Apex returns the registrable domain of host. Hosts are compared case
+// insensitive, a trailing dot and leading wildcard are ignored. It returns
+// false for IP addresses, public suffixes and invalid hosts.
+func Apex(host string) (string, bool) {
+	host = clean(host)
+	if host == "" || strings.ContainsAny(host, " /:@") || net.ParseIP(host) != nil {
+		return "", false
+	}
+
+	apex, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return "", false
+	}
+
+	return apex, true
+}
+
+// IsApex returns whether name is a registrable domain itself.
+func IsApex(name string) bool {
+	apex, ok := Apex(name)
+	return ok && apex == clean(name)
+}
+
+func clean(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	host = strings.TrimPrefix(host, "*.")
+	return strings.TrimSuffix(host, ".")
+}
+
+// Node is a domain in a tree of hostnames. The roots of the tree are apex
+// domains, the children of a node the domains one level below it.
+type Node struct {
+	Name string `json:"name"`
+	// Count is the number of hostnames of the node and all nodes below.
+	Count int `json:"count"`
+	// Record tells whether the name itself is one of the hostnames.
+	Record   bool    `json:"record"`
+	Children []*Node `json:"children,omitempty"`
+
+	children map[string]*Node
+}
+
+// Tree groups hostnames by apex domain and subdomain level.
+type Tree struct {
+	roots map[string]*Node
+	// Other is the number of hostnames without an apex domain, like IP
+	// addresses.
+	Other int
+}
+
+// NewTree returns an empty tree.
+func NewTree() *Tree {
+	return &Tree{roots: make(map[string]*Node)}
+}
+
+// Add adds a hostname to the tree. Hostnames are counted once per call, so
+// the same hostname should only be added once.
+func (t *Tree) Add(host string) {
+	apex, ok := Apex(host)
+	if !ok {
+		t.Other++
+		return
+	}
+
+	node := child(t.roots, apex)
+	node.Count++
+
+	// walk down the labels in front of the apex domain, api.dev.example.com
+	// is added below dev.example.com
+	host = clean(host)
+	labels := strings.Split(strings.TrimSuffix(strings.TrimSuffix(host, apex), "."), ".")
+	name := apex
+	for i := len(labels) - 1; i >= 0 && labels[i] != ""; i-- {
+		name = labels[i] + "." + name
+
+		if node.children == nil {
+			node.children = make(map[string]*Node)
+		}
+		node = child(node.children, name)
+		node.Count++
+	}
+
+	node.Record = true
+}
+
+// Roots returns the apex domains sorted by name, with their children sorted
+// the same way.
+func (t *Tree) Roots() []*Node {
+	return sorted(t.roots)
+}
+
+func child(nodes map[string]*Node, name string) *Node {
+	node, ok := nodes[name]
+	if !ok {
+		node = &Node{Name: name}
+		nodes[name] = node
+	}
+	return node
+}
+
+func sorted(nodes map[string]*Node) []*Node {
+	list := make([]*Node, 0, len(nodes))
+	for _, node := range nodes {
+		node.Children = sorted(node.children)
+		list = append(list, node)
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+
+	return list
+}
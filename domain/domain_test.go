@@ -0,0 +1,81 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApex(t *testing.T) {
+	tests := []struct {
+		host string
+		apex string
+		ok   bool
+	}{
+		{"example.com", "example.com", true},
+		{"API.Dev.Example.com.", "example.com", true},
+		{"*.example.com", "example.com", true},
+		{"www.example.co.uk", "example.co.uk", true},
+		{"foo.github.io", "foo.github.io", true},
+		{"co.uk", "", false},
+		{"10.0.0.1", "", false},
+		{"https://example.com", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			apex, ok := Apex(tt.host)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.apex, apex)
+		})
+	}
+}
+
+func TestIsApex(t *testing.T) {
+	assert.True(t, IsApex("example.co.uk"))
+	assert.False(t, IsApex("www.example.co.uk"))
+	assert.False(t, IsApex("co.uk"))
+}
+
+func TestTree(t *testing.T) {
+	tree := NewTree()
+	for _, host := range []string{
+		"api.dev.example.com",
+		"dev.example.com",
+		"www.example.com",
+		"example.org",
+		"10.0.0.1",
+	} {
+		tree.Add(host)
+	}
+
+	assert.Equal(t, 1, tree.Other)
+	assert.Equal(t, []*Node{
+		{
+			Name:  "example.com",
+			Count: 3,
+			Children: []*Node{
+				{
+					Name:   "dev.example.com",
+					Count:  2,
+					Record: true,
+					Children: []*Node{
+						{Name: "api.dev.example.com", Count: 1, Record: true, Children: []*Node{}},
+					},
+				},
+				{Name: "www.example.com", Count: 1, Record: true, Children: []*Node{}},
+			},
+		},
+		{Name: "example.org", Count: 1, Record: true, Children: []*Node{}},
+	}, withoutLookup(tree.Roots()))
+}
+
+// withoutLookup removes the lookup maps, which are not part of the result.
+func withoutLookup(nodes []*Node) []*Node {
+	for _, node := range nodes {
+		node.children = nil
+		withoutLookup(node.Children)
+	}
+	return nodes
+}
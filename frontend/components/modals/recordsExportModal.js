@@ -46,10 +46,14 @@ export default function RecordsExportModal({ showModal, setShowModal, id, contai
                     </p>
 
                     <div className="font-mono border p-5 text-sm my-3">
-                      <span id="curl">curl -s &quot;{apiUrl}/box/{id}/{container}&quot;</span>
-                      <span className="text-gray-400"> | jq -r &apos;.records[].data&apos;</span>
+                      <span id="curl">curl -s &quot;{apiUrl}/box/{id}/{container}/_export&quot;</span>
                     </div>
 
+                    <p className="text-sm text-gray-500">
+                      Add <code>?format=csv</code> or <code>?format=jsonl</code> to include tags and timestamps,
+                      and <code>term=</code> to only export matching records.
+                    </p>
+
                   </div>
                 </div>
               </div>
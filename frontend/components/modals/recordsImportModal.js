@@ -54,7 +54,7 @@ export default function RecordsImportModal({ showModal, setShowModal, id, contai
                     </p>
 
                     <div className="font-mono border p-5 text-sm my-3">
-                      <span className="text-gray-400">echo example.com | </span>curl --data-binary @- &quot;{apiUrl}/box/{id}/{container}<span className="font-bold">?tags=is_scope,is_wildcard</span>&quot;
+                      <span className="text-gray-400">echo example.com | </span>curl --data-binary @- &quot;{apiUrl}/box/{id}/{container}<span className="font-bold">?tags=source:manual</span>&quot;
                     </div>
                   </div>
                 </div>
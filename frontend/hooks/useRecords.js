@@ -4,13 +4,20 @@ const fetcher = (url) => fetch(url).then((res) => res.json())
 
 export default function useRecords(boxId, container, filter, limit, page) {
   const offset = page * limit;
-  const { data, mutate, error } = useSWR(boxId ? `/api/box/${boxId}/${container}?term=${filter}&limit=${limit}&offset=${offset}` : null, fetcher)
+  const term = encodeURIComponent(filter)
+  const { data, mutate, error } = useSWR(boxId ? `/api/box/${boxId}/${container}?term=${term}&limit=${limit}&offset=${offset}&count=false` : null, fetcher)
+
+  // the count does not change between pages, so it is only fetched once per term
+  const { data: countData, mutate: mutateCount } = useSWR(boxId ? `/api/box/${boxId}/${container}/_count?term=${term}` : null, fetcher)
 
   return {
     records: data?.records,
-    count: data?.count,
+    count: countData?.count,
     isLoading: !error && !data,
     isError: error,
-    mutate
+    mutate: () => {
+      mutateCount()
+      return mutate()
+    }
   }
 }
@@ -97,7 +97,7 @@ export default function AutomationShow() {
           <div className="flex items-center space-x-4">
             <div className="max-w-2xl text-sm text-gray-500">
               Targeting{' '}
-              <Link href={`/records/?id=${automation.box_id}&container=${automation.source_container}&term=${automation.source_tags?.map(t => `tag:${t}`).join(' ')}`}><a className="text-blue-600">{automation.source_count} entries</a></Link>{' '}
+              <Link href={`/records/?id=${automation.box_id}&container=${automation.source_container}&term=${encodeURIComponent([...(automation.source_tags || []).map(t => `tag:${t}`), automation.source_term].filter(t => t).join(' '))}`}><a className="text-blue-600">{automation.source_count} entries</a></Link>{' '}
               currently
             </div>
             <button
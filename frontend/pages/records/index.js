@@ -114,7 +114,7 @@ export default function Home() {
                   name="filter"
                   id="filter"
                   className="focus:ring-0 focus:border-0 block w-full text-sm text-gray-600 border-0"
-                  placeholder="Filter: foo.com tag:is_scope"
+                  placeholder="Filter: foo.com tag:in_scope"
                   autoComplete=""
                   value={filterInput}
                   onChange={(e) => setFilterInput(e.target.value)}
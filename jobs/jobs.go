@@ -49,7 +49,7 @@ var finishedJobsLog = func(ctx context.Context, j *gue.Job, err error) {
 	}
 }
 
-func Init(dbUrl string, repo *db.Queries) (*gue.Client, context.CancelFunc) {
+func Init(dbUrl string, repo *db.Queries, quotas db.Quotas) (*gue.Client, context.CancelFunc) {
 	pgxCfg, err := pgxpool.ParseConfig(dbUrl)
 	if err != nil {
 		log.Fatal(err)
@@ -69,6 +69,7 @@ func Init(dbUrl string, repo *db.Queries) (*gue.Client, context.CancelFunc) {
 	js := Jobserver{
 		repo:   repo,
 		dbPool: pgxPool,
+		quotas: quotas,
 	}
 
 	wm := gue.WorkMap{
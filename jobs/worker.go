@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"hntr/db"
+	"io"
 	"log"
 	"os/exec"
 	"strings"
@@ -25,16 +26,23 @@ type RunAutomationArgs struct {
 type Jobserver struct {
 	repo   *db.Queries
 	dbPool *pgxpool.Pool
+	quotas db.Quotas
+}
+
+// insertResult carries the outcome of storing the output of a command.
+type insertResult struct {
+	result db.InsertResult
+	err    error
 }
 
 const REPLACE = "{data}"
 
 var JOB_MAX_TIME = 60 * time.Second
 
-func executeCommand(ctx context.Context, jobArgs RunAutomationArgs, deadline time.Duration, dbPool *pgxpool.Pool, quotaLimit int64) (chan int64, error) {
-
-	results := make(chan int64)
-
+// executeCommand runs the command of the automation and stores its output.
+// The output is read completely before waiting for the command, the error
+// returned is the one of the command.
+func executeCommand(ctx context.Context, jobArgs RunAutomationArgs, deadline time.Duration, dbPool *pgxpool.Pool, opts db.InsertOptions) (insertResult, error) {
 	ctxTimed, cancel := context.WithTimeout(ctx, deadline)
 	defer cancel()
 
@@ -44,26 +52,19 @@ func executeCommand(ctx context.Context, jobArgs RunAutomationArgs, deadline tim
 	cmd := exec.CommandContext(ctxTimed, "bash", "-c", quotedCmd)
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return results, fmt.Errorf("error updating job status: %v", err)
+		return insertResult{}, fmt.Errorf("error updating job status: %v", err)
 	}
 
-	go func() {
+	if err := cmd.Start(); err != nil {
+		return insertResult{}, err
+	}
 
-		affected := db.RecordsBatchInsert(
-			ctx,
-			dbPool,
-			stdout,
-			jobArgs.Automation.BoxID,
-			jobArgs.Automation.DestinationContainer,
-			jobArgs.Automation.DestinationTags,
-			quotaLimit,
-			false,
-		)
+	result, insertErr := db.RecordsBatchInsert(ctx, dbPool, db.NewLineReader(stdout), opts)
 
-		results <- affected
-	}()
+	// the command must not block on output which is not read anymore
+	io.Copy(io.Discard, stdout)
 
-	return results, cmd.Run()
+	return insertResult{result: result, err: insertErr}, cmd.Wait()
 }
 
 func (js *Jobserver) RunAutomation(ctx context.Context, j *gue.Job) error {
@@ -80,9 +81,46 @@ func (js *Jobserver) RunAutomation(ctx context.Context, j *gue.Job) error {
 		return nil
 	}
 
-	results, err := executeCommand(ctx, args, JOB_MAX_TIME, js.dbPool, 10)
+	box, err := js.repo.GetBox(ctx, args.Automation.BoxID)
+	if err != nil {
+		log.Printf("error getting box of job: %v", err)
+		return nil
+	}
 
-	affectedRows := int32(<-results)
+	opts := db.InsertOptions{
+		BoxID:         args.Automation.BoxID,
+		Container:     args.Automation.DestinationContainer,
+		Tags:          args.Automation.DestinationTags,
+		Quota:         box.Quotas(js.quotas).Records,
+		DuplicateMode: db.DuplicateMerge,
+		Parent: &db.Parent{
+			Container:    args.Automation.SourceContainer,
+			Data:         args.Data,
+			AutomationID: args.Automation.ID,
+		},
+		Source: &db.Source{
+			Kind:         db.SourceAutomation,
+			EventID:      args.JobID,
+			AutomationID: args.Automation.ID,
+			Command:      args.Automation.Command,
+			Input:        args.Data,
+		},
+	}
+
+	if err := box.Apply(args.Automation.DestinationContainer, &opts); err != nil {
+		log.Printf("error loading box settings: %v", err)
+		return nil
+	}
+
+	inserted, err := executeCommand(ctx, args, JOB_MAX_TIME, js.dbPool, opts)
+	result := inserted.result
+	affectedRows := int32(result.Affected)
+
+	// e.g. an exceeded quota, in which case none of the results are stored
+	if err == nil && inserted.err != nil {
+		log.Printf("error storing job results: %v", inserted.err)
+		err = inserted.err
+	}
 
 	if err != nil {
 		if err.Error() == "signal: killed" {
@@ -90,6 +128,7 @@ func (js *Jobserver) RunAutomation(ctx context.Context, j *gue.Job) error {
 				Status:       "timeout",
 				ID:           args.JobID,
 				AffectedRows: affectedRows,
+				Report:       result.JSONB(),
 			}); err != nil {
 				log.Printf("error updating job status: %v", err)
 			}
@@ -97,6 +136,7 @@ func (js *Jobserver) RunAutomation(ctx context.Context, j *gue.Job) error {
 			if err := js.repo.UpdateAutomationEventStatusFinished(ctx, db.UpdateAutomationEventStatusFinishedParams{
 				Status: "error",
 				ID:     args.JobID,
+				Report: result.JSONB(),
 			}); err != nil {
 				log.Printf("error updating job status: %v", err)
 			}
@@ -110,6 +150,7 @@ func (js *Jobserver) RunAutomation(ctx context.Context, j *gue.Job) error {
 		Status:       "finished",
 		ID:           args.JobID,
 		AffectedRows: affectedRows,
+		Report:       result.JSONB(),
 	}); err != nil {
 		log.Printf("error updating job status: %v", err)
 	}
@@ -0,0 +1,3 @@
+ALTER TABLE records ADD COLUMN attributes JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+CREATE INDEX idx_records_attributes ON records USING GIN (attributes jsonb_path_ops);
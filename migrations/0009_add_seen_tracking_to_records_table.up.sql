@@ -0,0 +1,6 @@
+ALTER TABLE records ADD COLUMN last_seen_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+ALTER TABLE records ADD COLUMN seen_count INT NOT NULL DEFAULT 1;
+
+UPDATE records SET last_seen_at = created_at;
+
+CREATE INDEX idx_records_last_seen ON records(box_id, container, last_seen_at);
@@ -0,0 +1,8 @@
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE EXTENSION IF NOT EXISTS btree_gin;
+
+-- substring (LIKE '%term%') and pattern searches within a container
+CREATE INDEX idx_records_data_trgm ON records USING GIN (box_id, container, data gin_trgm_ops);
+
+-- tag containment searches (tags @> ARRAY[...]) within a container
+CREATE INDEX idx_records_tags ON records USING GIN (box_id, container, tags);
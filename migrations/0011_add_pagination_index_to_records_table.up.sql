@@ -0,0 +1 @@
+CREATE INDEX idx_records_pagination ON records(box_id, container, created_at DESC, data DESC);
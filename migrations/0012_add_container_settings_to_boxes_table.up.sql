@@ -0,0 +1 @@
+ALTER TABLE boxes ADD COLUMN container_settings JSONB NOT NULL DEFAULT '{}';
@@ -0,0 +1 @@
+ALTER TABLE boxes ADD COLUMN scope JSONB NOT NULL DEFAULT '{}';
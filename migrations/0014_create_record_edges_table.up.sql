@@ -0,0 +1,28 @@
+CREATE TABLE record_edges (
+    box_id              uuid NOT NULL,
+
+    source_container    varchar(20) NOT NULL,
+    source_data         VARCHAR(250) NOT NULL,
+    target_container    varchar(20) NOT NULL,
+    target_data         VARCHAR(250) NOT NULL,
+
+    automation_id       uuid,
+
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (box_id, source_container, source_data, target_container, target_data),
+
+    CONSTRAINT fk_source
+      FOREIGN KEY(box_id, source_container, source_data)
+        REFERENCES records(box_id, container, data) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    CONSTRAINT fk_target
+      FOREIGN KEY(box_id, target_container, target_data)
+        REFERENCES records(box_id, container, data) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    CONSTRAINT fk_automation
+      FOREIGN KEY(automation_id)
+        REFERENCES automations(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_record_edges_target ON record_edges(box_id, target_container, target_data);
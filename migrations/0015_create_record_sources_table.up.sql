@@ -0,0 +1,27 @@
+-- event_id is kept without a foreign key, as automation events are removed
+-- after a few days while the provenance of a record should stay
+CREATE TABLE record_sources (
+    box_id          uuid NOT NULL,
+    container       varchar(20) NOT NULL,
+    data            VARCHAR(250) NOT NULL,
+
+    kind            text NOT NULL,
+    event_id        uuid,
+    automation_id   uuid,
+    command         text NOT NULL DEFAULT '',
+    input           text NOT NULL DEFAULT '',
+
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fk_record
+      FOREIGN KEY(box_id, container, data)
+        REFERENCES records(box_id, container, data) ON DELETE CASCADE ON UPDATE CASCADE,
+
+    CONSTRAINT fk_automation
+      FOREIGN KEY(automation_id)
+        REFERENCES automations(id) ON DELETE SET NULL
+);
+
+-- a record is stored once per event, and once for all uploads
+CREATE UNIQUE INDEX idx_record_sources_unique ON record_sources(box_id, container, data, COALESCE(event_id, '00000000-0000-0000-0000-000000000000'));
+CREATE INDEX idx_record_sources_event ON record_sources(event_id);
@@ -0,0 +1,55 @@
+-- Records are identified by a hash of their data instead of the data itself,
+-- which keeps the primary key small and allows data exceeding the size limit
+-- of btree index entries, like long urls.
+CREATE FUNCTION record_hash(data text) RETURNS bytea
+    LANGUAGE sql IMMUTABLE STRICT PARALLEL SAFE
+    AS $$ SELECT sha256(convert_to(data, 'UTF8')) $$;
+
+ALTER TABLE record_edges DROP CONSTRAINT fk_source, DROP CONSTRAINT fk_target;
+ALTER TABLE record_sources DROP CONSTRAINT fk_record;
+
+DROP INDEX idx_records_pagination;
+
+ALTER TABLE records ALTER COLUMN data TYPE VARCHAR(8192);
+ALTER TABLE records ADD COLUMN data_hash bytea GENERATED ALWAYS AS (record_hash(data)) STORED;
+ALTER TABLE records DROP CONSTRAINT records_pkey;
+ALTER TABLE records ADD PRIMARY KEY (box_id, container, data_hash);
+
+-- data is only a tie breaker for records created at the same time
+CREATE INDEX idx_records_pagination ON records(box_id, container, created_at DESC);
+
+-- the hashes of edges and sources are set on insert, as generated columns
+-- can not be updated by ON UPDATE CASCADE
+ALTER TABLE record_edges
+    ALTER COLUMN source_data TYPE VARCHAR(8192),
+    ALTER COLUMN target_data TYPE VARCHAR(8192),
+    ADD COLUMN source_hash bytea,
+    ADD COLUMN target_hash bytea;
+UPDATE record_edges SET source_hash = record_hash(source_data), target_hash = record_hash(target_data);
+ALTER TABLE record_edges
+    ALTER COLUMN source_hash SET NOT NULL,
+    ALTER COLUMN target_hash SET NOT NULL,
+    DROP CONSTRAINT record_edges_pkey,
+    ADD PRIMARY KEY (box_id, source_container, source_hash, target_container, target_hash),
+    ADD CONSTRAINT fk_source
+      FOREIGN KEY(box_id, source_container, source_hash)
+        REFERENCES records(box_id, container, data_hash) ON DELETE CASCADE ON UPDATE CASCADE,
+    ADD CONSTRAINT fk_target
+      FOREIGN KEY(box_id, target_container, target_hash)
+        REFERENCES records(box_id, container, data_hash) ON DELETE CASCADE ON UPDATE CASCADE;
+
+DROP INDEX idx_record_edges_target;
+CREATE INDEX idx_record_edges_target ON record_edges(box_id, target_container, target_hash);
+
+ALTER TABLE record_sources
+    ALTER COLUMN data TYPE VARCHAR(8192),
+    ADD COLUMN data_hash bytea;
+UPDATE record_sources SET data_hash = record_hash(data);
+ALTER TABLE record_sources
+    ALTER COLUMN data_hash SET NOT NULL,
+    ADD CONSTRAINT fk_record
+      FOREIGN KEY(box_id, container, data_hash)
+        REFERENCES records(box_id, container, data_hash) ON DELETE CASCADE ON UPDATE CASCADE;
+
+DROP INDEX idx_record_sources_unique;
+CREATE UNIQUE INDEX idx_record_sources_unique ON record_sources(box_id, container, data_hash, COALESCE(event_id, '00000000-0000-0000-0000-000000000000'));
@@ -0,0 +1,2 @@
+-- the ingest report of the results, see db.InsertResult
+ALTER TABLE automation_events ADD COLUMN report JSONB;
@@ -0,0 +1,2 @@
+-- quotas of a box, NULL uses the defaults of the instance
+ALTER TABLE boxes ADD COLUMN records_quota bigint, ADD COLUMN events_quota bigint;
@@ -0,0 +1,36 @@
+-- snapshots keep the records of a box at a point in time, either taken
+-- manually or before an automation run
+CREATE TABLE snapshots (
+    id              uuid DEFAULT uuid_generate_v4 (),
+    box_id          uuid NOT NULL,
+    name            VARCHAR(50) NOT NULL,
+    kind            text NOT NULL,
+    automation_id   uuid,
+
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id),
+
+    CONSTRAINT fk_box
+      FOREIGN KEY(box_id)
+        REFERENCES boxes(id) ON DELETE CASCADE,
+
+    CONSTRAINT fk_automation
+      FOREIGN KEY(automation_id)
+        REFERENCES automations(id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_snapshots_box ON snapshots(box_id, created_at DESC);
+
+CREATE TABLE snapshot_records (
+    snapshot_id     uuid NOT NULL,
+    container       varchar(20) NOT NULL,
+    data            VARCHAR(8192) NOT NULL,
+    data_hash       bytea GENERATED ALWAYS AS (record_hash(data)) STORED,
+    tags            VARCHAR(50)[],
+
+    PRIMARY KEY (snapshot_id, container, data_hash),
+
+    CONSTRAINT fk_snapshot
+      FOREIGN KEY(snapshot_id)
+        REFERENCES snapshots(id) ON DELETE CASCADE
+);
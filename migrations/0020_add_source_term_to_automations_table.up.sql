@@ -0,0 +1,3 @@
+-- automations select their source records by a search term in addition to
+-- the source tags
+ALTER TABLE automations ADD COLUMN source_term text NOT NULL DEFAULT '';
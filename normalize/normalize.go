@@ -0,0 +1,118 @@
+// Package normalize rewrites record data into a canonical form before it is
+// stored, so that e.g. `Foo.Example.com.` and `foo.example.com` end up as the
+// same record. Normalizers are configured by name per container.
+package normalize
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// Func normalizes data. It returns false if the record should be dropped.
+type Func func(data string) (string, bool)
+
+var funcs = map[string]Func{
+	"lowercase":      lowercase,
+	"trim_dot":       trimDot,
+	"strip_wildcard": stripWildcard,
+	"url":            canonicalURL,
+	"drop_empty":     dropEmpty,
+}
+
+// Names returns the names of all normalizers.
+func Names() []string {
+	names := make([]string, 0, len(funcs))
+	for name := range funcs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Pipeline applies normalizers in order. The zero value leaves data as is.
+type Pipeline []Func
+
+// New returns a pipeline of the named normalizers.
+func New(names []string) (Pipeline, error) {
+	p := make(Pipeline, 0, len(names))
+
+	for _, name := range names {
+		fn, ok := funcs[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown normalizer %q, use one of %s", name, strings.Join(Names(), ", "))
+		}
+		p = append(p, fn)
+	}
+
+	return p, nil
+}
+
+// Apply runs data through all normalizers and stops as soon as one drops it.
+func (p Pipeline) Apply(data string) (string, bool) {
+	for _, fn := range p {
+		var ok bool
+		if data, ok = fn(data); !ok {
+			return "", false
+		}
+	}
+
+	return data, true
+}
+
+func lowercase(data string) (string, bool) {
+	return strings.ToLower(data), true
+}
+
+// trimDot removes the trailing dot of fully qualified hostnames.
+func trimDot(data string) (string, bool) {
+	return strings.TrimRight(data, "."), true
+}
+
+// stripWildcard turns `*.example.com` into `example.com`.
+func stripWildcard(data string) (string, bool) {
+	for strings.HasPrefix(data, "*.") {
+		data = data[2:]
+	}
+
+	return data, true
+}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// canonicalURL lowercases scheme and host, removes default ports and trailing
+// slashes of the path. Data which is not an absolute url is kept unchanged.
+func canonicalURL(data string) (string, bool) {
+	u, err := url.Parse(data)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return data, true
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+
+	if port := u.Port(); port != "" && port == defaultPorts[u.Scheme] {
+		u.Host = strings.TrimSuffix(u.Host, ":"+port)
+	}
+
+	// only literal slashes are trimmed, the escaped path is kept as escaping
+	// can change what the path refers to
+	rawPath := strings.TrimRight(u.EscapedPath(), "/")
+	path, err := url.PathUnescape(rawPath)
+	if err != nil {
+		return data, true
+	}
+	u.Path = path
+	u.RawPath = rawPath
+
+	return u.String(), true
+}
+
+func dropEmpty(data string) (string, bool) {
+	return data, data != ""
+}
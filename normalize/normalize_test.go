@@ -0,0 +1,43 @@
+package normalize
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		names []string
+		data  string
+		want  string
+		ok    bool
+	}{
+		{nil, "Foo.Example.com.", "Foo.Example.com.", true},
+		{[]string{"lowercase", "trim_dot"}, "Foo.Example.com.", "foo.example.com", true},
+		{[]string{"strip_wildcard"}, "*.foo.example.com", "foo.example.com", true},
+		{[]string{"url"}, "HTTPS://Example.com:443/", "https://example.com", true},
+		{[]string{"url"}, "http://example.com:8080/foo/?a=b", "http://example.com:8080/foo?a=b", true},
+		{[]string{"url"}, "example.com/foo/", "example.com/foo/", true},
+		{[]string{"url"}, "https://example.com/a%2Fb/", "https://example.com/a%2Fb", true},
+		{[]string{"url"}, "https://example.com/a%2F", "https://example.com/a%2F", true},
+		{[]string{"drop_empty"}, "", "", false},
+		{[]string{"strip_wildcard", "drop_empty"}, "*.", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			p, err := New(tt.names)
+			assert.Nil(t, err)
+
+			got, ok := p.Apply(tt.data)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewUnknown(t *testing.T) {
+	_, err := New([]string{"lowercase", "foo"})
+	assert.NotNil(t, err)
+}
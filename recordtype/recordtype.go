@@ -0,0 +1,85 @@
+// Package recordtype validates record data against the type of the container
+// it is stored in.
+package recordtype
+
+import (
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+)
+
+const (
+	// Text accepts any data and is used for containers without a type.
+	Text     = "text"
+	Hostname = "hostname"
+	URL      = "url"
+	IP       = "ip"
+	CIDR     = "cidr"
+	Email    = "email"
+)
+
+var checks = map[string]func(data string) bool{
+	Text:     func(string) bool { return true },
+	Hostname: isHostname,
+	URL:      isURL,
+	IP:       func(data string) bool { return net.ParseIP(data) != nil },
+	CIDR:     isCIDR,
+	Email:    isEmail,
+}
+
+// Known reports whether t is a valid type. The empty type is treated as Text.
+func Known(t string) bool {
+	_, ok := checks[t]
+	return ok || t == ""
+}
+
+// Valid reports whether data is valid for type t. Data of unknown types is
+// always valid.
+func Valid(t string, data string) bool {
+	check, ok := checks[t]
+	if !ok {
+		return true
+	}
+
+	return check(data)
+}
+
+// detected lists the types Detect tries, the more specific ones first.
+var detected = []string{URL, Email, CIDR, IP, Hostname}
+
+// Detect returns the type of data in a container without a type. Data of
+// none of the other types is Text.
+func Detect(data string) string {
+	for _, t := range detected {
+		if checks[t](data) {
+			return t
+		}
+	}
+
+	return Text
+}
+
+// labels of letters, digits, hyphens and underscores separated by at least
+// one dot
+var hostnameRe = regexp.MustCompile(`^(?i)([a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?\.)+[a-z0-9_]([a-z0-9_-]{0,61}[a-z0-9_])?$`)
+
+func isHostname(data string) bool {
+	return len(data) <= 253 && hostnameRe.MatchString(data)
+}
+
+func isURL(data string) bool {
+	u, err := url.Parse(data)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func isCIDR(data string) bool {
+	_, _, err := net.ParseCIDR(data)
+	return err == nil
+}
+
+// isEmail only accepts plain addresses without a display name.
+func isEmail(data string) bool {
+	addr, err := mail.ParseAddress(data)
+	return err == nil && addr.Address == data
+}
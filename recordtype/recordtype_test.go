@@ -0,0 +1,68 @@
+package recordtype
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		t     string
+		data  string
+		valid bool
+	}{
+		{Text, "[INF] Current version: 1.0", true},
+		{"", "anything", true},
+		{Hostname, "foo.example.com", true},
+		{Hostname, "_dmarc.example.com", true},
+		{Hostname, "xn--bcher-kva.example", true},
+		{Hostname, "localhost", false},
+		{Hostname, "*.example.com", false},
+		{Hostname, "[INF] Enumerating subdomains for example.com", false},
+		{Hostname, "-foo.example.com", false},
+		{URL, "https://example.com/foo", true},
+		{URL, "example.com/foo", false},
+		{IP, "10.0.0.1", true},
+		{IP, "2001:db8::1", true},
+		{IP, "10.0.0.256", false},
+		{CIDR, "10.0.0.0/8", true},
+		{CIDR, "10.0.0.1", false},
+		{Email, "foo@example.com", true},
+		{Email, "Foo <foo@example.com>", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.t+" "+tt.data, func(t *testing.T) {
+			assert.Equal(t, tt.valid, Valid(tt.t, tt.data))
+		})
+	}
+}
+
+func TestKnown(t *testing.T) {
+	assert.True(t, Known(""))
+	assert.True(t, Known(Hostname))
+	assert.False(t, Known("foo"))
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		data string
+		t    string
+	}{
+		{"foo.example.com", Hostname},
+		{"https://example.com/foo", URL},
+		{"foo@example.com", Email},
+		{"10.0.0.1", IP},
+		{"2001:db8::1", IP},
+		{"10.0.0.0/8", CIDR},
+		{"localhost", Text},
+		{"[INF] Enumerating subdomains for example.com", Text},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			assert.Equal(t, tt.t, Detect(tt.data))
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Package scope decides whether records are in the scope of a box.
+//
+// Scope rules are either domains, CIDRs or regular expressions:
+//
+//	example.com      the domain itself
+//	*.example.com    the domain and all of its subdomains
+//	10.0.0.0/8       all addresses in the network
+//	/^api\./         data matching the regular expression
+//
+// Domain and CIDR rules are matched against the host of urls and email
+// addresses, regular expressions always against the whole data. Networks are
+// matched by CIDR rules containing the whole network.
+package scope
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ModeTag tags records with TagIn or TagOut.
+	ModeTag = "tag"
+	// ModeDrop drops out of scope records and tags the others with TagIn.
+	ModeDrop = "drop"
+)
+
+const (
+	TagIn  = "in_scope"
+	TagOut = "out_of_scope"
+)
+
+// Rules are the scope rules of a box as stored in its scope column.
+type Rules struct {
+	// Include rules, data matching none of them is out of scope. Without
+	// include rules all data not excluded is in scope.
+	Include []string `json:"include"`
+	// Exclude rules take precedence over include rules.
+	Exclude []string `json:"exclude"`
+	// Mode defines how out of scope records are ingested, defaults to ModeTag.
+	Mode string `json:"mode,omitempty"`
+}
+
+type matcher func(host string, data string) bool
+
+// Scope holds compiled rules. A nil Scope has no rules.
+type Scope struct {
+	include []matcher
+	exclude []matcher
+	mode    string
+}
+
+// New compiles rules. It returns nil if there are no rules.
+func New(r Rules) (*Scope, error) {
+	if len(r.Include) == 0 && len(r.Exclude) == 0 {
+		return nil, nil
+	}
+
+	s := &Scope{mode: r.Mode}
+
+	switch r.Mode {
+	case "":
+		s.mode = ModeTag
+	case ModeTag, ModeDrop:
+	default:
+		return nil, fmt.Errorf("unknown mode %q, use %s or %s", r.Mode, ModeTag, ModeDrop)
+	}
+
+	var err error
+	if s.include, err = compile(r.Include); err != nil {
+		return nil, err
+	}
+	if s.exclude, err = compile(r.Exclude); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func compile(rules []string) ([]matcher, error) {
+	matchers := make([]matcher, 0, len(rules))
+
+	for _, rule := range rules {
+		m, err := compileRule(strings.TrimSpace(rule))
+		if err != nil {
+			return nil, err
+		}
+		matchers = append(matchers, m)
+	}
+
+	return matchers, nil
+}
+
+func compileRule(rule string) (matcher, error) {
+	if len(rule) > 1 && strings.HasPrefix(rule, "/") && strings.HasSuffix(rule, "/") {
+		re, err := regexp.Compile(rule[1 : len(rule)-1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %v", rule, err)
+		}
+
+		return func(host string, data string) bool {
+			return re.MatchString(data)
+		}, nil
+	}
+
+	if strings.Contains(rule, "/") {
+		_, network, err := net.ParseCIDR(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid rule %q: %v", rule, err)
+		}
+
+		ruleOnes, ruleBits := network.Mask.Size()
+
+		return func(host string, data string) bool {
+			if ip := net.ParseIP(host); ip != nil {
+				return network.Contains(ip)
+			}
+
+			_, n, err := net.ParseCIDR(host)
+			if err != nil {
+				return false
+			}
+
+			ones, bits := n.Mask.Size()
+			return bits == ruleBits && ones >= ruleOnes && network.Contains(n.IP)
+		}, nil
+	}
+
+	if rule == "" || strings.ContainsAny(rule, " :") {
+		return nil, fmt.Errorf("invalid rule %q", rule)
+	}
+
+	domain := strings.ToLower(strings.TrimRight(rule, "."))
+	if strings.HasPrefix(domain, "*.") {
+		suffix := domain[1:]
+		return func(host string, data string) bool {
+			return host == suffix[1:] || strings.HasSuffix(host, suffix)
+		}, nil
+	}
+
+	return func(host string, data string) bool {
+		return host == domain
+	}, nil
+}
+
+// Mode returns how out of scope records are ingested.
+func (s *Scope) Mode() string {
+	if s == nil {
+		return ModeTag
+	}
+
+	return s.mode
+}
+
+// Contains reports whether data is in scope. Everything is in the scope of
+// a nil Scope.
+func (s *Scope) Contains(data string) bool {
+	if s == nil {
+		return true
+	}
+
+	host := hostOf(data)
+
+	for _, m := range s.exclude {
+		if m(host, data) {
+			return false
+		}
+	}
+
+	if len(s.include) == 0 {
+		return true
+	}
+
+	for _, m := range s.include {
+		if m(host, data) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hostOf returns the lowercased host of urls, email addresses and host:port
+// pairs, or data itself.
+func hostOf(data string) string {
+	host := data
+
+	if u, err := url.Parse(data); err == nil && u.Host != "" {
+		host = u.Hostname()
+	} else if addr, err := mail.ParseAddress(data); err == nil {
+		host = addr.Address[strings.LastIndex(addr.Address, "@")+1:]
+	} else if h, _, err := net.SplitHostPort(data); err == nil {
+		host = h
+	}
+
+	return strings.ToLower(strings.TrimRight(host, "."))
+}
@@ -0,0 +1,77 @@
+package scope
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContains(t *testing.T) {
+	s, err := New(Rules{
+		Include: []string{"*.example.com", "example.org", "10.0.0.0/8", `/^dev-[0-9]+$/`},
+		Exclude: []string{"*.corp.example.com", "10.0.0.1/32"},
+	})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		data    string
+		inScope bool
+	}{
+		{"example.com", true},
+		{"API.Example.com.", true},
+		{"https://api.example.com:8443/login", true},
+		{"admin@example.com", true},
+		{"api.example.com:443", true},
+		{"corp.example.com", false},
+		{"vpn.corp.example.com", false},
+		{"notexample.com", false},
+		{"example.org", true},
+		{"www.example.org", false},
+		{"10.1.2.3", true},
+		{"10.0.0.1", false},
+		{"http://10.1.2.3/", true},
+		{"192.168.0.1", false},
+		{"10.2.0.0/16", true},
+		{"10.0.0.1/32", false},
+		{"0.0.0.0/0", false},
+		{"192.168.0.0/16", false},
+		{"dev-12", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.data, func(t *testing.T) {
+			assert.Equal(t, tt.inScope, s.Contains(tt.data))
+		})
+	}
+}
+
+func TestExcludeOnly(t *testing.T) {
+	s, err := New(Rules{Exclude: []string{"*.corp.example.com"}})
+	assert.Nil(t, err)
+
+	assert.True(t, s.Contains("api.example.com"))
+	assert.False(t, s.Contains("vpn.corp.example.com"))
+}
+
+func TestNoRules(t *testing.T) {
+	s, err := New(Rules{Mode: ModeDrop})
+	assert.Nil(t, err)
+	assert.Nil(t, s)
+	assert.True(t, s.Contains("anything"))
+	assert.Equal(t, ModeTag, s.Mode())
+}
+
+func TestInvalidRules(t *testing.T) {
+	rules := []Rules{
+		{Include: []string{"10.0.0.0/33"}},
+		{Include: []string{"/(/"}},
+		{Include: []string{""}},
+		{Exclude: []string{"foo bar"}},
+		{Include: []string{"example.com"}, Mode: "foo"},
+	}
+
+	for _, r := range rules {
+		_, err := New(r)
+		assert.NotNil(t, err)
+	}
+}
@@ -0,0 +1,650 @@
+// Package search implements the search term syntax used to filter records
+// and compiles it into a parameterized SQL condition.
+//
+// A term consists of whitespace separated expressions which all have to
+// match. Supported expressions are:
+//
+//	foo                  data contains foo
+//	"foo bar"            data contains the phrase foo bar
+//	*.example.com        data matches the pattern, * is a wildcard
+//	data:foo.com         data equals foo.com (or matches if it contains *)
+//	data:/^api\./        data matches the regular expression
+//	tag:foo              record is tagged with foo (tag:source:* for prefixes)
+//	attr:key=value       attribute key equals value
+//	attr:key             attribute key exists
+//	created:>2022-01-01  created_at comparison, also <, <=, >= or a day
+//	last_seen:<30d       last_seen_at comparison with an absolute or relative
+//	                     time (h, d, w), here: not seen for 30 days
+//	seen_count:>2        seen_count comparison
+//	apex:example.com     hostname has the registrable domain example.com
+//
+// Expressions can be negated with a leading -, combined with OR and grouped
+// with parentheses, e.g. `-tag:oos (tag:source:amass OR tag:source:gau)`.
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"hntr/domain"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxExpressions limits the number of expressions in a single term.
+const MaxExpressions = 50
+
+// Query is a parsed search term.
+type Query struct {
+	root node
+}
+
+// Parse parses a search term. An empty term matches all records.
+func Parse(term string) (*Query, error) {
+	return parse(term, time.Now())
+}
+
+// MatchTags returns a query matching records tagged with all of the given
+// tags.
+func MatchTags(tags []string) *Query {
+	q := &Query{}
+	for _, t := range tags {
+		if t == "" {
+			continue
+		}
+		q = q.And(&Query{root: &tagNode{value: t}})
+	}
+	return q
+}
+
+// And returns a query matching both q and other.
+func (q *Query) And(other *Query) *Query {
+	if q == nil || q.root == nil {
+		return other
+	}
+	if other == nil || other.root == nil {
+		return q
+	}
+	return &Query{root: &andNode{children: []node{q.root, other.root}}}
+}
+
+// Empty reports whether the query has no conditions and matches all records.
+func (q *Query) Empty() bool {
+	return q == nil || q.root == nil
+}
+
+// Where compiles the query into a SQL condition for the records table.
+// Placeholders are numbered starting after offset, the values for them are
+// returned in order.
+func (q *Query) Where(offset int) (string, []interface{}) {
+	b := &builder{offset: offset, args: make([]interface{}, 0)}
+	if q.Empty() {
+		return "TRUE", b.args
+	}
+	return q.root.sql(b), b.args
+}
+
+type builder struct {
+	offset int
+	args   []interface{}
+}
+
+// arg registers a value and returns its placeholder.
+func (b *builder) arg(v interface{}) string {
+	b.args = append(b.args, v)
+	return fmt.Sprintf("$%d", b.offset+len(b.args))
+}
+
+type node interface {
+	sql(b *builder) string
+}
+
+type andNode struct{ children []node }
+type orNode struct{ children []node }
+type notNode struct{ child node }
+
+func (n *andNode) sql(b *builder) string { return joinNodes(b, n.children, " AND ") }
+func (n *orNode) sql(b *builder) string  { return joinNodes(b, n.children, " OR ") }
+
+func (n *notNode) sql(b *builder) string {
+	// tags or attributes may be NULL, which must not hide the record
+	return "NOT COALESCE(" + n.child.sql(b) + ", FALSE)"
+}
+
+func joinNodes(b *builder, children []node, sep string) string {
+	parts := make([]string, 0, len(children))
+	for _, c := range children {
+		parts = append(parts, c.sql(b))
+	}
+	return "(" + strings.Join(parts, sep) + ")"
+}
+
+// containsNode matches records containing value.
+type containsNode struct{ value string }
+
+func (n *containsNode) sql(b *builder) string {
+	return "data LIKE " + b.arg("%"+escapeLike(n.value)+"%")
+}
+
+// patternNode matches records against a pattern where * is a wildcard.
+type patternNode struct{ value string }
+
+func (n *patternNode) sql(b *builder) string {
+	return "data LIKE " + b.arg(wildcardToLike(n.value))
+}
+
+type equalsNode struct{ value string }
+
+func (n *equalsNode) sql(b *builder) string {
+	return "data = " + b.arg(n.value)
+}
+
+type regexNode struct{ value string }
+
+func (n *regexNode) sql(b *builder) string {
+	return "data ~ " + b.arg(n.value)
+}
+
+type tagNode struct{ value string }
+
+func (n *tagNode) sql(b *builder) string {
+	if strings.Contains(n.value, "*") {
+		return "EXISTS (SELECT 1 FROM unnest(tags) t WHERE t LIKE " + b.arg(wildcardToLike(n.value)) + ")"
+	}
+	return "tags @> ARRAY[" + b.arg(n.value) + "]::varchar[]"
+}
+
+// apexNode matches hostnames having the registrable domain value, including
+// wildcards like *.example.com.
+type apexNode struct{ value string }
+
+func (n *apexNode) sql(b *builder) string {
+	return "(lower(data) = " + b.arg(n.value) + " OR lower(data) LIKE " + b.arg("%."+escapeLike(n.value)) + ")"
+}
+
+type attributeNode struct {
+	key      string
+	value    interface{}
+	hasValue bool
+}
+
+func (n *attributeNode) sql(b *builder) string {
+	if !n.hasValue {
+		return "attributes ? " + b.arg(n.key)
+	}
+
+	filter, _ := json.Marshal(map[string]interface{}{n.key: n.value})
+	return "attributes @> " + b.arg(string(filter)) + "::jsonb"
+}
+
+type compareNode struct {
+	column string
+	op     string
+	value  interface{}
+}
+
+func (n *compareNode) sql(b *builder) string {
+	return n.column + " " + n.op + " " + b.arg(n.value)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func wildcardToLike(s string) string {
+	return strings.Replace(escapeLike(s), "*", "%", -1)
+}
+
+// fields which may prefix a value, e.g. tag:foo
+var fields = map[string]bool{
+	"data":       true,
+	"tag":        true,
+	"attr":       true,
+	"created":    true,
+	"last_seen":  true,
+	"seen_count": true,
+	"apex":       true,
+}
+
+var timeColumns = map[string]string{
+	"created":   "created_at",
+	"last_seen": "last_seen_at",
+}
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenLParen
+	tokenRParen
+	tokenOr
+	tokenNot
+)
+
+type token struct {
+	kind   tokenKind
+	field  string
+	value  string
+	quoted bool
+	regex  bool
+	// start and end of a term in the runes of the search term
+	start int
+	end   int
+}
+
+func lex(term string) ([]token, error) {
+	tokens := make([]token, 0)
+	runes := []rune(term)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, token{kind: tokenLParen})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{kind: tokenRParen})
+			i++
+		case r == '-' && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]):
+			tokens = append(tokens, token{kind: tokenNot})
+			i++
+		default:
+			t, next, err := lexTerm(runes, i)
+			if err != nil {
+				return nil, err
+			}
+			t.start, t.end = i, next
+			tokens = append(tokens, t)
+			i = next
+		}
+	}
+
+	return tokens, nil
+}
+
+func lexTerm(runes []rune, i int) (token, int, error) {
+	t := token{kind: tokenTerm}
+
+	// a field prefix consists of lowercase letters and underscores only
+	j := i
+	for j < len(runes) && (unicode.IsLower(runes[j]) || runes[j] == '_') {
+		j++
+	}
+	if j < len(runes) && runes[j] == ':' && fields[string(runes[i:j])] {
+		t.field = string(runes[i:j])
+		i = j + 1
+	}
+
+	if i < len(runes) && runes[i] == '"' {
+		value, next, err := readDelimited(runes, i, '"')
+		if err != nil {
+			return t, 0, err
+		}
+		t.value, t.quoted = value, true
+		return t, next, nil
+	}
+
+	if t.field == "data" && i < len(runes) && runes[i] == '/' {
+		value, next, err := readDelimited(runes, i, '/')
+		if err != nil {
+			return t, 0, err
+		}
+		t.value, t.regex = value, true
+		return t, next, nil
+	}
+
+	j = i
+	for j < len(runes) && !unicode.IsSpace(runes[j]) && runes[j] != ')' {
+		j++
+	}
+	t.value = string(runes[i:j])
+
+	if t.field == "" && t.value == "OR" {
+		t.kind = tokenOr
+	}
+
+	return t, j, nil
+}
+
+// readDelimited reads a value enclosed by delim starting at runes[i]. The
+// delimiter can be escaped with a backslash.
+func readDelimited(runes []rune, i int, delim rune) (string, int, error) {
+	var sb strings.Builder
+
+	for j := i + 1; j < len(runes); j++ {
+		if runes[j] == '\\' && j+1 < len(runes) && runes[j+1] == delim {
+			sb.WriteRune(delim)
+			j++
+			continue
+		}
+		if runes[j] == delim {
+			return sb.String(), j + 1, nil
+		}
+		sb.WriteRune(runes[j])
+	}
+
+	return "", 0, fmt.Errorf("missing closing %c", delim)
+}
+
+// Tags returns the tags term refers to with tag: expressions, prefixes like
+// tag:source:* included as they are written.
+func Tags(term string) ([]string, error) {
+	tokens, err := lex(term)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]string, 0)
+	for _, t := range tokens {
+		if t.kind == tokenTerm && t.field == "tag" {
+			tags = append(tags, t.value)
+		}
+	}
+
+	return tags, nil
+}
+
+// RenameTag returns term with all tag:tag expressions referring to newTag
+// instead, and whether any of them changed. The rest of term is kept as is.
+func RenameTag(term string, tag string, newTag string) (string, bool, error) {
+	tokens, err := lex(term)
+	if err != nil {
+		return term, false, err
+	}
+
+	runes := []rune(term)
+	var sb strings.Builder
+	last := 0
+	changed := false
+
+	for _, t := range tokens {
+		if t.kind != tokenTerm || t.field != "tag" || t.value != tag {
+			continue
+		}
+
+		sb.WriteString(string(runes[last:t.start]))
+		sb.WriteString("tag:" + quoteValue(newTag))
+		last = t.end
+		changed = true
+	}
+
+	if !changed {
+		return term, false, nil
+	}
+
+	sb.WriteString(string(runes[last:]))
+	return sb.String(), true, nil
+}
+
+// quoteValue quotes value if it would not be read as a single value
+// otherwise.
+func quoteValue(value string) string {
+	if value != "" && strings.IndexFunc(value, func(r rune) bool {
+		return unicode.IsSpace(r) || r == ')' || r == '"'
+	}) < 0 {
+		return value
+	}
+
+	return `"` + strings.ReplaceAll(value, `"`, `\"`) + `"`
+}
+
+type parser struct {
+	tokens      []token
+	pos         int
+	now         time.Time
+	expressions int
+}
+
+func parse(term string, now time.Time) (*Query, error) {
+	tokens, err := lex(term)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return &Query{}, nil
+	}
+
+	p := &parser{tokens: tokens, now: now}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected )")
+	}
+
+	return &Query{root: root}, nil
+}
+
+func (p *parser) peek() *token {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+func (p *parser) parseOr() (node, error) {
+	children := make([]node, 0)
+
+	for {
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, n)
+
+		if t := p.peek(); t == nil || t.kind != tokenOr {
+			break
+		}
+		p.pos++
+	}
+
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &orNode{children: children}, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	children := make([]node, 0)
+
+	for {
+		t := p.peek()
+		if t == nil || t.kind == tokenOr || t.kind == tokenRParen {
+			break
+		}
+
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, n)
+	}
+
+	switch len(children) {
+	case 0:
+		return nil, fmt.Errorf("missing expression")
+	case 1:
+		return children[0], nil
+	}
+	return &andNode{children: children}, nil
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+
+	switch t.kind {
+	case tokenNot:
+		p.pos++
+		if p.peek() == nil {
+			return nil, fmt.Errorf("missing expression after -")
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &notNode{child: child}, nil
+	case tokenLParen:
+		p.pos++
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t == nil || t.kind != tokenRParen {
+			return nil, fmt.Errorf("missing closing )")
+		}
+		p.pos++
+		return n, nil
+	case tokenOr:
+		return nil, fmt.Errorf("unexpected OR")
+	case tokenRParen:
+		return nil, fmt.Errorf("unexpected )")
+	}
+
+	p.pos++
+	p.expressions++
+	if p.expressions > MaxExpressions {
+		return nil, fmt.Errorf("too many expressions. MAX_EXPRESSIONS=%v", MaxExpressions)
+	}
+
+	return p.term(*t)
+}
+
+func (p *parser) term(t token) (node, error) {
+	switch t.field {
+	case "":
+		if !t.quoted && strings.Contains(t.value, "*") {
+			return &patternNode{value: t.value}, nil
+		}
+		return &containsNode{value: t.value}, nil
+	case "data":
+		if t.regex {
+			if _, err := regexp.Compile(t.value); err != nil {
+				return nil, fmt.Errorf("invalid regular expression: %v", err)
+			}
+			return &regexNode{value: t.value}, nil
+		}
+		if !t.quoted && strings.Contains(t.value, "*") {
+			return &patternNode{value: t.value}, nil
+		}
+		return &equalsNode{value: t.value}, nil
+	case "tag":
+		if t.value == "" {
+			return nil, fmt.Errorf("missing tag")
+		}
+		return &tagNode{value: t.value}, nil
+	case "attr":
+		kv := strings.SplitN(t.value, "=", 2)
+		if kv[0] == "" {
+			return nil, fmt.Errorf("missing attribute name")
+		}
+		if len(kv) == 1 {
+			return &attributeNode{key: kv[0]}, nil
+		}
+		return &attributeNode{key: kv[0], value: attributeValue(kv[1], t.quoted), hasValue: true}, nil
+	case "apex":
+		if !domain.IsApex(t.value) {
+			return nil, fmt.Errorf("%q is not a registrable domain", t.value)
+		}
+		return &apexNode{value: strings.ToLower(strings.TrimSuffix(t.value, "."))}, nil
+	case "created", "last_seen":
+		return p.timeComparison(timeColumns[t.field], t.value)
+	case "seen_count":
+		op, value := splitOperator(t.value)
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", value)
+		}
+		return &compareNode{column: "seen_count", op: op, value: count}, nil
+	}
+
+	return nil, fmt.Errorf("unknown field %q", t.field)
+}
+
+func (p *parser) timeComparison(column string, raw string) (node, error) {
+	op, value := splitOperator(raw)
+
+	// a plain date without operator matches the whole day
+	if op == "=" {
+		day, err := time.Parse("2006-01-02", value)
+		if err == nil {
+			return &andNode{children: []node{
+				&compareNode{column: column, op: ">=", value: day},
+				&compareNode{column: column, op: "<", value: day.AddDate(0, 0, 1)},
+			}}, nil
+		}
+
+		// a relative time without operator means "since"
+		op = ">="
+	}
+
+	t, err := parseTime(value, p.now)
+	if err != nil {
+		return nil, err
+	}
+
+	return &compareNode{column: column, op: op, value: t}, nil
+}
+
+func splitOperator(value string) (string, string) {
+	for _, op := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(value, op) {
+			return op, value[len(op):]
+		}
+	}
+	return "=", value
+}
+
+// attributeValue keeps numbers, booleans and other JSON literals typed
+// unless the value was quoted.
+func attributeValue(value string, quoted bool) interface{} {
+	if quoted {
+		return value
+	}
+
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return value
+	}
+	return parsed
+}
+
+// parseTime parses either a date (2006-01-02), a RFC3339 timestamp or a
+// relative age like 12h, 30d or 2w which is subtracted from now.
+func parseTime(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	if len(value) < 2 {
+		return time.Time{}, fmt.Errorf("invalid time value %q", value)
+	}
+
+	amount, err := strconv.Atoi(value[:len(value)-1])
+	if err != nil || amount < 0 {
+		return time.Time{}, fmt.Errorf("invalid time value %q", value)
+	}
+
+	switch value[len(value)-1] {
+	case 'h':
+		return now.Add(-time.Duration(amount) * time.Hour), nil
+	case 'd':
+		return now.AddDate(0, 0, -amount), nil
+	case 'w':
+		return now.AddDate(0, 0, -amount*7), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time value %q", value)
+}
@@ -0,0 +1,137 @@
+package search
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWhere(t *testing.T) {
+	now := time.Date(2022, 2, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		term string
+		sql  string
+		args []interface{}
+	}{
+		{"", "TRUE", []interface{}{}},
+		{"foo", "data LIKE $3", []interface{}{"%foo%"}},
+		{"foo_1 100%", "(data LIKE $3 AND data LIKE $4)", []interface{}{`%foo\_1%`, `%100\%%`}},
+		{`"foo bar"`, "data LIKE $3", []interface{}{"%foo bar%"}},
+		{"*.dev.example.com", "data LIKE $3", []interface{}{"%.dev.example.com"}},
+		{"data:api.example.com", "data = $3", []interface{}{"api.example.com"}},
+		{"data:*.dev.example.com", "data LIKE $3", []interface{}{"%.dev.example.com"}},
+		{`data:/^api\./`, "data ~ $3", []interface{}{`^api\.`}},
+		{`data:/a\/b/`, "data ~ $3", []interface{}{`a/b`}},
+		{"tag:foo", "tags @> ARRAY[$3]::varchar[]", []interface{}{"foo"}},
+		{"tag:source:*", "EXISTS (SELECT 1 FROM unnest(tags) t WHERE t LIKE $3)", []interface{}{"source:%"}},
+		{"-tag:foo", "NOT COALESCE(tags @> ARRAY[$3]::varchar[], FALSE)", []interface{}{"foo"}},
+		{"attr:status=200", "attributes @> $3::jsonb", []interface{}{`{"status":200}`}},
+		{"attr:title", "attributes ? $3", []interface{}{"title"}},
+		{"seen_count:>2", "seen_count > $3", []interface{}{2}},
+		{"created:>2022-01-01", "created_at > $3", []interface{}{time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)}},
+		{"last_seen:<30d", "last_seen_at < $3", []interface{}{now.AddDate(0, 0, -30)}},
+		{"created:2022-01-01", "(created_at >= $3 AND created_at < $4)", []interface{}{
+			time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, 1, 2, 0, 0, 0, 0, time.UTC),
+		}},
+		{"tag:a OR tag:b", "(tags @> ARRAY[$3]::varchar[] OR tags @> ARRAY[$4]::varchar[])", []interface{}{"a", "b"}},
+		{"foo (tag:a OR -tag:b)", "(data LIKE $3 AND (tags @> ARRAY[$4]::varchar[] OR NOT COALESCE(tags @> ARRAY[$5]::varchar[], FALSE)))", []interface{}{"%foo%", "a", "b"}},
+		{"https://foo.com", "data LIKE $3", []interface{}{"%https://foo.com%"}},
+		{"apex:Example.co.uk", "(lower(data) = $3 OR lower(data) LIKE $4)", []interface{}{"example.co.uk", `%.example.co.uk`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			q, err := parse(tt.term, now)
+			assert.Nil(t, err)
+
+			sql, args := q.Where(2)
+			assert.Equal(t, tt.sql, sql)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	terms := []string{
+		`"foo`,
+		"(tag:a",
+		"tag:a)",
+		"OR foo",
+		"foo OR",
+		"tag:",
+		"data:/(/",
+		"seen_count:>many",
+		"created:>yesterday",
+		"apex:co.uk",
+		"apex:www.example.com",
+	}
+
+	for _, term := range terms {
+		t.Run(term, func(t *testing.T) {
+			_, err := Parse(term)
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestMatchTags(t *testing.T) {
+	sql, args := MatchTags([]string{"a", "", "b"}).Where(0)
+
+	assert.Equal(t, "(tags @> ARRAY[$1]::varchar[] AND tags @> ARRAY[$2]::varchar[])", sql)
+	assert.Equal(t, []interface{}{"a", "b"}, args)
+
+	sql, _ = MatchTags(nil).Where(0)
+	assert.Equal(t, "TRUE", sql)
+}
+
+func TestEmpty(t *testing.T) {
+	for _, term := range []string{"", "  ", "\t\n"} {
+		q, err := Parse(term)
+		assert.Nil(t, err)
+		assert.True(t, q.Empty())
+	}
+
+	q, err := Parse("tag:a")
+	assert.Nil(t, err)
+	assert.False(t, q.Empty())
+}
+
+func TestTags(t *testing.T) {
+	tags, err := Tags(`-tag:oos (tag:"src gau" OR tag:source:*) foo`)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"oos", "src gau", "source:*"}, tags)
+
+	_, err = Tags(`tag:"open`)
+	assert.NotNil(t, err)
+}
+
+func TestRenameTag(t *testing.T) {
+	tests := []struct {
+		term    string
+		newTag  string
+		renamed string
+		changed bool
+	}{
+		{"tag:a", "b", "tag:b", true},
+		{"foo -tag:a (tag:a OR tag:c)", "b", "foo -tag:b (tag:b OR tag:c)", true},
+		{`tag:"a" created:>7d`, "b c", `tag:"b c" created:>7d`, true},
+		{"tag:a)", `b"`, `tag:"b\"")`, true},
+		{"tag:ab a data:a", "b", "tag:ab a data:a", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			renamed, changed, err := RenameTag(tt.term, "a", tt.newTag)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.renamed, renamed)
+			assert.Equal(t, tt.changed, changed)
+
+			tags, err := Tags(renamed)
+			assert.Nil(t, err)
+			assert.NotContains(t, tags, "a")
+		})
+	}
+}
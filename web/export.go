@@ -0,0 +1,95 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"hntr/db"
+	"io"
+	"strings"
+	"time"
+)
+
+// exportFormat describes how records are written by ExportRecords.
+type exportFormat struct {
+	contentType string
+	extension   string
+	writer      func(w io.Writer) recordWriter
+}
+
+var exportFormats = map[string]exportFormat{
+	"txt": {
+		contentType: "text/plain; charset=utf-8",
+		extension:   "txt",
+		writer:      func(w io.Writer) recordWriter { return &textWriter{w: w} },
+	},
+	"csv": {
+		contentType: "text/csv; charset=utf-8",
+		extension:   "csv",
+		writer:      newCSVWriter,
+	},
+	"jsonl": {
+		contentType: MIME_NDJSON,
+		extension:   "jsonl",
+		writer:      func(w io.Writer) recordWriter { return &jsonlWriter{enc: json.NewEncoder(w)} },
+	},
+}
+
+type recordWriter interface {
+	Write(record db.Record) error
+	Flush() error
+}
+
+// textWriter writes the data of a record per line.
+type textWriter struct {
+	w io.Writer
+}
+
+func (t *textWriter) Write(record db.Record) error {
+	_, err := io.WriteString(t.w, record.Data+"\n")
+	return err
+}
+
+func (t *textWriter) Flush() error {
+	return nil
+}
+
+// csvWriter writes a header row followed by data, tags and created_at of
+// every record. Tags are joined by a comma.
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) recordWriter {
+	c := &csvWriter{w: csv.NewWriter(w)}
+
+	// errors are kept by the csv writer and returned on Flush
+	_ = c.w.Write([]string{"data", "tags", "created_at"})
+
+	return c
+}
+
+func (c *csvWriter) Write(record db.Record) error {
+	return c.w.Write([]string{
+		record.Data,
+		strings.Join(record.Tags, ","),
+		record.CreatedAt.Format(time.RFC3339),
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonlWriter writes every record as a json object per line.
+type jsonlWriter struct {
+	enc *json.Encoder
+}
+
+func (j *jsonlWriter) Write(record db.Record) error {
+	return j.enc.Encode(record)
+}
+
+func (j *jsonlWriter) Flush() error {
+	return nil
+}
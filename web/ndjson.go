@@ -0,0 +1,123 @@
+package web
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"hntr/db"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const MIME_NDJSON = "application/x-ndjson"
+
+// number of rejected lines for which the error is reported back
+const NDJSON_ERRORS_MAX = 10
+
+// maximum length of a line, longer lines stop the import
+const NDJSON_LINE_MAX = 1024 * 1024
+
+type NDJSONRecord struct {
+	Data       string                 `json:"data" validate:"required,max=8192"`
+	Tags       []string               `json:"tags" validate:"max=10,dive,max=50"`
+	Attributes map[string]interface{} `json:"attrs" validate:"max=20,dive,keys,min=1,max=50,endkeys"`
+}
+
+// ndjsonReader reads records from newline delimited json, where every line
+// holds a single NDJSONRecord. Lines failing validation are skipped and
+// counted as rejected.
+type ndjsonReader struct {
+	scanner  *bufio.Scanner
+	validate func(i interface{}) error
+	tags     []string
+
+	current  db.RecordInput
+	line     int
+	rejected int
+	errors   []string
+}
+
+func newNDJSONReader(reader io.Reader, tags []string, validate func(i interface{}) error) *ndjsonReader {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), NDJSON_LINE_MAX)
+
+	return &ndjsonReader{
+		scanner:  scanner,
+		validate: validate,
+		tags:     tags,
+		errors:   make([]string, 0),
+	}
+}
+
+func (r *ndjsonReader) Scan() bool {
+	for r.scanner.Scan() {
+		r.line++
+
+		raw := strings.TrimSpace(r.scanner.Text())
+		if raw == "" {
+			continue
+		}
+
+		record := NDJSONRecord{}
+		if err := json.Unmarshal([]byte(raw), &record); err != nil {
+			r.reject("invalid json")
+			continue
+		}
+
+		if err := r.validate(record); err != nil {
+			if errors, ok := err.(validator.ValidationErrors); ok {
+				firstError := errors[0]
+				r.reject(fmt.Sprintf("%s: %s", firstError.Field(), validationErrorMsg(firstError)))
+			} else {
+				r.reject(err.Error())
+			}
+			continue
+		}
+
+		tags := cleanTags(record.Tags)
+		if len(db.MergeTags(r.tags, tags)) > TAGS_MAX {
+			r.reject(fmt.Sprintf("too many tags. MAX_TAGS=%v", TAGS_MAX))
+			continue
+		}
+
+		r.current = db.RecordInput{
+			Data:       record.Data,
+			Tags:       tags,
+			Attributes: record.Attributes,
+		}
+
+		return true
+	}
+
+	// the scanner can not continue after a line exceeding its buffer
+	if r.scanner.Err() == bufio.ErrTooLong {
+		r.line++
+		r.reject(fmt.Sprintf("line too long, stopped reading. MAX=%v", NDJSON_LINE_MAX))
+	}
+
+	return false
+}
+
+func (r *ndjsonReader) Record() db.RecordInput {
+	return r.current
+}
+
+func isNDJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+
+	return mediaType == MIME_NDJSON
+}
+
+func (r *ndjsonReader) reject(msg string) {
+	r.rejected++
+
+	if len(r.errors) < NDJSON_ERRORS_MAX {
+		r.errors = append(r.errors, fmt.Sprintf("line %d: %s", r.line, msg))
+	}
+}
@@ -0,0 +1,41 @@
+package web
+
+import (
+	"errors"
+	"hntr/db"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// setQuotaHeaders reports the record quota of the box after an insert. The
+// limit and remaining records are left out if the quota is unlimited.
+func setQuotaHeaders(c echo.Context, usage db.Usage) {
+	h := c.Response().Header()
+	h.Set("X-Quota-Used", strconv.FormatInt(usage.Count, 10))
+
+	if usage.Quota == 0 {
+		return
+	}
+
+	h.Set("X-Quota-Limit", strconv.FormatInt(usage.Quota, 10))
+	h.Set("X-Quota-Remaining", strconv.FormatInt(usage.Remaining(), 10))
+}
+
+// insertError responds to a failed insert. Exceeding a quota is reported
+// along with the report of the insert, so clients can tell how many records
+// would fit.
+func insertError(c echo.Context, result db.InsertResult, err error) error {
+	var quotaErr *db.QuotaError
+	if errors.As(err, &quotaErr) {
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]interface{}{
+			"error":  err.Error(),
+			"report": result,
+		})
+	}
+
+	log.Printf("inserting records failed: %v", err)
+	return c.JSON(http.StatusInternalServerError, nil)
+}
@@ -0,0 +1,101 @@
+package web
+
+import (
+	"context"
+	"hntr/db"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetention(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "Testbox",
+		Containers: []string{"hostnames", "events"},
+	})
+	assert.Nil(err)
+
+	records := []db.CreateRecordParams{
+		{Data: "old.example.com", Container: "hostnames", Tags: []string{"transient"}},
+		{Data: "kept.example.com", Container: "hostnames"},
+		{Data: "new.example.com", Container: "hostnames", Tags: []string{"transient"}},
+		{Data: "old event", Container: "events"},
+		{Data: "new event", Container: "events"},
+	}
+	for _, record := range records {
+		record.BoxID = box.ID
+		assert.Nil(repo.CreateRecord(ctx, record))
+	}
+
+	_, err = dbc.Exec(ctx, `UPDATE records SET last_seen_at = NOW() - '10 days'::interval WHERE box_id = $1 AND data IN ('old.example.com', 'kept.example.com', 'old event')`, box.ID)
+	assert.Nil(err)
+
+	settings := func(container, body string, status int) {
+		req := httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/"+container+"/_settings", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(status, rec.Code)
+	}
+
+	settings("events", `{"retention": [{"days": 0}]}`, http.StatusBadRequest)
+	settings("events", `{"retention": [{"days": 7}]}`, http.StatusOK)
+	settings("hostnames", `{"retention": [{"days": 7, "tag": "transient"}]}`, http.StatusOK)
+
+	expired, err := repo.DeleteExpired(ctx)
+	assert.Nil(err)
+
+	deleted := map[string]int64{}
+	for _, e := range expired {
+		if e.BoxID == box.ID {
+			deleted[e.Container] += e.Deleted
+		}
+	}
+	assert.Equal(map[string]int64{"hostnames": 1, "events": 1}, deleted)
+
+	remaining, err := repo.ListRecordsByBoxFilter(ctx, db.ListRecordsByBoxFilterParams{
+		BoxID:     box.ID,
+		Container: "hostnames",
+		Column3:   []string{},
+		Data:      "%%",
+	})
+	assert.Nil(err)
+	assert.Len(remaining, 2)
+
+	tags := func(method, url, body string) {
+		req := httptest.NewRequest(method, "/api/box/"+box.ID.String()+"/_tags"+url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Code)
+	}
+
+	retention := func(container string) []db.RetentionRule {
+		b, err := repo.GetBox(ctx, box.ID)
+		assert.Nil(err)
+		return b.Settings(container).Retention
+	}
+
+	t.Run("rename the tag of a rule", func(t *testing.T) {
+		tags(http.MethodPut, "", `{"tag": "transient", "new_tag": "temporary"}`)
+		assert.Equal([]db.RetentionRule{{Days: 7, Tag: "temporary"}}, retention("hostnames"))
+		assert.Equal([]db.RetentionRule{{Days: 7}}, retention("events"))
+	})
+
+	t.Run("remove the rules of a deleted tag", func(t *testing.T) {
+		tags(http.MethodDelete, "?tag=temporary", "")
+		assert.Len(retention("hostnames"), 0)
+		assert.Equal([]db.RetentionRule{{Days: 7}}, retention("events"))
+	})
+}
@@ -2,8 +2,11 @@ package web
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"hntr/db"
+	"hntr/scope"
+	"hntr/search"
 	"log"
 	"net/http"
 	"strconv"
@@ -12,6 +15,7 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/labstack/echo/v4"
 	"gopkg.in/alessio/shellescape.v1"
 )
@@ -29,10 +33,22 @@ type Automation struct {
 	Command              string   `json:"command" validate:"required,min=0,max=500"`
 	SourceContainer      string   `json:"source_container" validate:"required,min=0,max=500"`
 	SourceTags           []string `json:"source_tags" validate:"required,max=10,dive,min=1,max=50"`
+	SourceTerm           string   `json:"source_term" validate:"max=500"`
 	DestinationContainer string   `json:"destination_container" validate:"required,min=0,max=500"`
 	DestinationTags      []string `json:"destination_tags" validate:"required,max=10,dive,min=1,max=50"`
 }
 
+// sourceQuery returns the query selecting the source records of an
+// automation, which carry all source tags and match the source term.
+func sourceQuery(sourceTags []string, sourceTerm string) (*search.Query, error) {
+	term, err := search.Parse(sourceTerm)
+	if err != nil {
+		return nil, err
+	}
+
+	return search.MatchTags(sourceTags).And(term), nil
+}
+
 func (s *Server) ListAutomations(c echo.Context) error {
 	ctx := context.Background()
 
@@ -51,15 +67,17 @@ func (s *Server) ListAutomations(c echo.Context) error {
 	automationCounts := []AutomationHostnameCount{}
 
 	for _, automation := range automations {
-		params := db.CountRecordsByBoxFilterParams{
-			BoxID:     automation.BoxID,
-			Container: automation.SourceContainer,
-			Data:      "%%",
-			Column3:   automation.SourceTags,
+		var count int64
+
+		// terms are checked when stored, an invalid one counts nothing
+		if query, err := sourceQuery(automation.SourceTags, automation.SourceTerm); err == nil {
+			count, _ = s.repo.CountRecordsBySearch(ctx, db.CountRecordsBySearchParams{
+				BoxID:     automation.BoxID,
+				Container: automation.SourceContainer,
+				Query:     query,
+			})
 		}
 
-		count, _ := s.repo.CountRecordsByBoxFilter(ctx, params)
-
 		a := AutomationHostnameCount{
 			Automation:  automation,
 			SourceCount: count,
@@ -204,18 +222,11 @@ func (s *Server) UpdateAutomationEvent(c echo.Context) error {
 	ctx := context.Background()
 
 	// ignore for now
-	id, err := uuid.Parse(c.Param("id"))
-	if err != nil {
+	if _, err := uuid.Parse(c.Param("id")); err != nil {
 		log.Printf("unable to parse id: %v", err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	count, err := s.repo.CountRecordsByBox(ctx, id)
-	if err != nil {
-		log.Printf("getting box count failed: %v", err)
-		return c.NoContent(http.StatusInternalServerError)
-	}
-
 	jobId, err := uuid.Parse(c.Param("jobid"))
 	if err != nil {
 		log.Printf("unable to parse id: %v", err)
@@ -234,34 +245,99 @@ func (s *Server) UpdateAutomationEvent(c echo.Context) error {
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	updateDuplicate := false
+	box, err := s.repo.GetBox(ctx, automation.BoxID)
+	if err != nil {
+		log.Printf("unable to get box of automation: %v", err)
+		return c.NoContent(http.StatusInternalServerError)
+	}
+
+	// results of different automations often overlap, so tags are merged
+	// unless explicitly asked to replace them
+	duplicateMode := db.DuplicateMerge
 	if c.QueryParam("update") != "" {
-		updateDuplicate = true
+		duplicateMode = db.DuplicateReplace
+	}
+
+	opts := db.InsertOptions{
+		BoxID:         automation.BoxID,
+		Container:     automation.DestinationContainer,
+		Tags:          automation.DestinationTags,
+		Quota:         box.Quotas(s.quotas).Records,
+		DuplicateMode: duplicateMode,
+		Parent: &db.Parent{
+			Container:    automation.SourceContainer,
+			Data:         job.Data,
+			AutomationID: automation.ID,
+		},
+		Source: &db.Source{
+			Kind:         db.SourceAutomation,
+			EventID:      job.ID,
+			AutomationID: automation.ID,
+			Command:      automation.Command,
+			Input:        job.Data,
+		},
+	}
+
+	if err := box.Apply(automation.DestinationContainer, &opts); err != nil {
+		log.Printf("loading box settings failed: %v", err)
+		return c.NoContent(http.StatusInternalServerError)
 	}
 
 	// retrieve automtion data
-	affected := db.RecordsBatchInsert(
-		ctx,
-		s.dbPool,
-		c.Request().Body,
-		automation.BoxID,
-		automation.DestinationContainer,
-		automation.DestinationTags,
-		int64(s.recordsLimit)-count-1,
-		updateDuplicate,
-	)
+	result, insertErr := db.RecordsBatchInsert(ctx, s.dbPool, db.NewLineReader(c.Request().Body), opts)
+
+	status := "finished"
+	if insertErr != nil {
+		status = "error"
+	}
 
 	err = s.repo.UpdateAutomationEventStatusFinished(ctx, db.UpdateAutomationEventStatusFinishedParams{
 		ID:           jobId,
-		Status:       "finished",
-		AffectedRows: int32(affected),
+		Status:       status,
+		AffectedRows: int32(result.Affected),
+		Report:       result.JSONB(),
 	})
 	if err != nil {
 		log.Printf("unable to update automation event: %v", err)
 		return c.NoContent(http.StatusInternalServerError)
 	}
 
-	return c.String(http.StatusOK, fmt.Sprintf("%v", affected))
+	setQuotaHeaders(c, result.Usage)
+
+	if insertErr != nil {
+		return insertError(c, result, insertErr)
+	}
+
+	return c.String(http.StatusOK, fmt.Sprintf("%v", result.Affected))
+}
+
+// ListAutomationEventRecords lists the records produced by an automation
+// event, including records which existed before.
+func (s *Server) ListAutomationEventRecords(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	jobId, err := uuid.Parse(c.Param("jobid"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	records, err := s.repo.ListRecordsByEvent(ctx, db.ListRecordsByEventParams{
+		BoxID:   id,
+		EventID: uuid.NullUUID{UUID: jobId, Valid: true},
+	})
+	if err != nil {
+		log.Printf("listing records of automation event failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"records": records,
+	})
 }
 
 func (s *Server) StartAutomation(c echo.Context) error {
@@ -283,94 +359,118 @@ func (s *Server) StartAutomation(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, nil)
 	}
 
-	// count automations to ensure automation log is not too big
+	// ensure box exists
+	box, err := s.repo.GetBox(ctx, automation.BoxID)
+
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, box)
+	}
+
+	// count automations to ensure automation log is not too big, the events
+	// about to be created are checked when they are
 	count, err := s.repo.CountAutomationEvents(ctx, automation.BoxID)
 	if err != nil {
 		log.Printf("getting automation event count failed: %v", err)
 		return c.JSON(http.StatusInternalServerError, nil)
 	}
 
-	if count >= int64(s.recordsLimit*2) {
+	quota := box.Quotas(s.quotas).Events
+	if quota > 0 && count >= quota {
 		return c.JSON(http.StatusBadRequest, map[string]string{
 			"error": "You event log backlog is too big, please clear some events first",
 		})
 	}
 
-	// ensure box exists
-	box, err := s.repo.GetBox(ctx, automation.BoxID)
+	if !inStringSlice(automation.SourceContainer, box.Containers) || !inStringSlice(automation.DestinationContainer, box.Containers) {
+		return c.JSON(http.StatusNotFound, nil)
+	}
 
+	boxScope, err := box.ContainerScope(automation.SourceContainer)
 	if err != nil {
-		if err == pgx.ErrNoRows {
-			return c.JSON(http.StatusNotFound, nil)
+		log.Printf("loading box scope failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if _, err := createAndEnqueue(ctx, s.dbPool, s.repo, automation, boxScope, box.Settings(automation.SourceContainer).Type, quota); err != nil {
+		if errors.Is(err, db.ErrEventsQuota) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("You event log backlog would get too big, please clear some events first (%v)", err),
+			})
 		}
 
-		log.Printf("getting box failed: %v", err)
-		return c.JSON(http.StatusInternalServerError, box)
+		log.Printf("error creating jobs: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
 	}
 
-	if !inStringSlice(automation.SourceContainer, box.Containers) || !inStringSlice(automation.DestinationContainer, box.Containers) {
-		return c.JSON(http.StatusNotFound, nil)
+	// keep the state at the start of the run to see what it changed, named
+	// after the automation. It is only taken once the events are scheduled.
+	// The run does not depend on it, so it is skipped on errors, e.g. if the
+	// snapshots would keep too many records.
+	name := []rune(automation.Name)
+	if len(name) > 50 {
+		name = name[:50]
 	}
 
-	go func(automation db.Automation, repo *db.Queries) {
-		if err := createAndEnqueue(context.Background(), automation, repo); err != nil {
-			log.Printf("error creating jobs: %v\n", err)
-		}
-	}(automation, s.repo)
+	if _, _, err := db.TakeSnapshot(ctx, s.dbPool, db.CreateSnapshotParams{
+		BoxID:        box.ID,
+		Name:         string(name),
+		Kind:         db.SnapshotAutomation,
+		AutomationID: uuid.NullUUID{UUID: automation.ID, Valid: true},
+	}, SNAPSHOTS_AUTOMATION_MAX, box.Quotas(s.quotas).Records); err != nil {
+		log.Printf("skipped snapshot of automation %v: %v", automation.ID, err)
+	}
 
 	return c.JSON(http.StatusOK, automation)
 }
 
-func createAndEnqueue(ctx context.Context, automation db.Automation, repo *db.Queries) error {
+// createAndEnqueue schedules an event for every record matching the source
+// of the automation. Records out of boxScope are never scheduled, if scope
+// rules apply to them in a source container of sourceType. The events are
+// only created if they all fit into quota.
+func createAndEnqueue(ctx context.Context, dbPool *pgxpool.Pool, repo *db.Queries, automation db.Automation, boxScope *scope.Scope, sourceType string, quota int64) (int64, error) {
+
+	// get all entries matching automation.source_table, automation.source_tags
+	// and automation.source_term
+	query, err := sourceQuery(automation.SourceTags, automation.SourceTerm)
+	if err != nil {
+		return 0, fmt.Errorf("parsing source term failed: %v", err)
+	}
 
-	// get all entries matching automation.source_table and automation.source_tags
-	params := db.ListRecordsByBoxFilterParams{
+	params := db.ListRecordsBySearchParams{
 		BoxID:     automation.BoxID,
 		Container: automation.SourceContainer,
-		Data:      "%%", // TODO: use other query here to optimize search
-		Column3:   automation.SourceTags,
+		Query:     query,
 	}
 
-	records, err := repo.ListRecordsByBoxFilter(ctx, params)
+	records, err := repo.ListRecordsBySearch(ctx, params)
 	if err != nil && err != pgx.ErrNoRows {
-		return fmt.Errorf("getting records failed: %v", err)
+		return 0, fmt.Errorf("getting records failed: %v", err)
 	}
 
-	// create and enqueue job for each entry
-	// TODO: do in a batch query
+	data := make([]string, 0, len(records))
 	for _, record := range records {
-
-		_, err := repo.CreateAutomationEvent(ctx, db.CreateAutomationEventParams{
-			BoxID:        automation.BoxID,
-			AutomationID: automation.ID,
-			Status:       "scheduled",
-			Data:         record.Data,
-		})
-		if err != nil {
-			return fmt.Errorf("error creating automation event: %v", err)
+		if db.Scoped(sourceType, record.Data) && !boxScope.Contains(record.Data) {
+			continue
 		}
+		data = append(data, record.Data)
+	}
 
-		// // encode arguments
-		// args, err := json.Marshal(jobs.RunAutomationArgs{
-		// 	JobID:      ae.ID,
-		// 	Automation: automation,
-		// 	Data:       record.Data,
-		// })
-		// if err != nil {
-		// 	return fmt.Errorf("error marshaling job: %v", err)
-		// }
-
-		// // create and enqueue job
-		// j := &gue.Job{
-		// 	Type: "RunAutomation",
-		// 	Args: args,
-		// }
-		// if err := queue.Enqueue(ctx, j); err != nil {
-		// 	return fmt.Errorf("error enqueueing job: %v", err)
-		// }
+	if skipped := len(records) - len(data); skipped > 0 {
+		log.Printf("skipped %d out of scope records for automation %v", skipped, automation.ID)
 	}
 
-	return nil
+	// the events are picked up by the job server
+	created, err := db.ScheduleAutomationEvents(ctx, dbPool, automation, data, quota)
+	if err != nil {
+		return 0, fmt.Errorf("error creating automation events: %w", err)
+	}
+
+	return created, nil
 }
 
 func (s *Server) ListAutomationLibrary(c echo.Context) error {
@@ -427,6 +527,12 @@ func (s *Server) AddAutomation(c echo.Context) error {
 			return c.JSON(http.StatusNotFound, nil)
 		}
 
+		if _, err := sourceQuery(automation.SourceTags, automation.SourceTerm); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("SourceTerm: invalid term: %v", err),
+			})
+		}
+
 		automationCreated, err := s.repo.CreateAutomation(ctx, db.CreateAutomationParams{
 			BoxID:                box.ID,
 			Name:                 automation.Name,
@@ -434,6 +540,7 @@ func (s *Server) AddAutomation(c echo.Context) error {
 			Command:              automation.Command,
 			SourceContainer:      automation.SourceContainer,
 			SourceTags:           automation.SourceTags,
+			SourceTerm:           automation.SourceTerm,
 			DestinationContainer: automation.DestinationContainer,
 			DestinationTags:      automation.DestinationTags,
 		})
@@ -472,6 +579,12 @@ func (s *Server) UpdateAutomation(c echo.Context) error {
 		})
 	}
 
+	if _, err := sourceQuery(automation.SourceTags, automation.SourceTerm); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("SourceTerm: invalid term: %v", err),
+		})
+	}
+
 	// if !inStringSlice(automation.SourceContainer, box.Containers) || !inStringSlice(automation.DestinationContainer, box.Containers) {
 	// 	return c.JSON(http.StatusNotFound, nil)
 	// }
@@ -482,6 +595,7 @@ func (s *Server) UpdateAutomation(c echo.Context) error {
 		Command:              automation.Command,
 		SourceContainer:      automation.SourceContainer,
 		SourceTags:           automation.SourceTags,
+		SourceTerm:           automation.SourceTerm,
 		DestinationContainer: automation.DestinationContainer,
 		DestinationTags:      automation.DestinationTags,
 		ID:                   id,
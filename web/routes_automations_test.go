@@ -2,9 +2,15 @@ package web
 
 import (
 	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
 	"hntr/db"
+	"hntr/scope"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -81,3 +87,349 @@ func TestRemoveAutomationEvents(t *testing.T) {
 	assert.Nil(err)
 	assert.Equal(int64(1), count)
 }
+
+// only in scope records are scheduled
+func TestCreateAndEnqueueScope(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	_, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "foo",
+		Containers: []string{"hostnames"},
+	})
+	assert.Nil(err)
+
+	automation, err := repo.CreateAutomation(ctx, db.CreateAutomationParams{
+		BoxID:                box.ID,
+		Name:                 "foo",
+		SourceContainer:      "hostnames",
+		DestinationContainer: "hostnames",
+	})
+	assert.Nil(err)
+
+	// scope rules do not apply to free text in the untyped container
+	for _, data := range []string{"api.example.com", "vpn.corp.example.com", "example.org", "[INF] done"} {
+		err = repo.CreateRecord(ctx, db.CreateRecordParams{
+			BoxID:     box.ID,
+			Data:      data,
+			Container: "hostnames",
+		})
+		assert.Nil(err)
+	}
+
+	boxScope, err := scope.New(scope.Rules{
+		Include: []string{"*.example.com"},
+		Exclude: []string{"*.corp.example.com"},
+	})
+	assert.Nil(err)
+
+	created, err := createAndEnqueue(ctx, dbc, repo, automation, boxScope, "", 0)
+	assert.Nil(err)
+	assert.Equal(int64(2), created)
+
+	events, err := repo.ListAutomationEvents(ctx, db.ListAutomationEventsParams{
+		AutomationID: automation.ID,
+		Limit:        10,
+	})
+	assert.Nil(err)
+	data := []string{}
+	for _, event := range events {
+		data = append(data, event.Data)
+	}
+	assert.ElementsMatch([]string{"api.example.com", "[INF] done"}, data)
+
+	// all events are checked against the quota at once
+	_, err = createAndEnqueue(ctx, dbc, repo, automation, nil, "", 3)
+	assert.True(errors.Is(err, db.ErrEventsQuota))
+
+	count, err := repo.CountAutomationEvents(ctx, box.ID)
+	assert.Nil(err)
+	assert.Equal(int64(2), count)
+}
+
+// boxes with an events quota of zero are not limited
+func TestStartAutomationUnlimited(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "foo",
+		Containers: []string{"hostnames"},
+	})
+	assert.Nil(err)
+
+	assert.Nil(repo.UpdateBoxQuota(ctx, db.UpdateBoxQuotaParams{
+		EventsQuota: sql.NullInt64{Int64: 0, Valid: true},
+		ID:          box.ID,
+	}))
+
+	automation, err := repo.CreateAutomation(ctx, db.CreateAutomationParams{
+		BoxID:                box.ID,
+		Name:                 "foo",
+		SourceContainer:      "hostnames",
+		DestinationContainer: "hostnames",
+	})
+	assert.Nil(err)
+
+	assert.Nil(repo.CreateRecord(ctx, db.CreateRecordParams{
+		BoxID:     box.ID,
+		Data:      "api.example.com",
+		Container: "hostnames",
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/automations/"+automation.ID.String()+"/start", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(http.StatusOK, rec.Code)
+
+	count, err := repo.CountAutomationEvents(ctx, box.ID)
+	assert.Nil(err)
+	assert.Equal(int64(1), count)
+
+	snapshots, err := repo.ListSnapshots(ctx, box.ID)
+	assert.Nil(err)
+	assert.Len(snapshots, 1)
+
+	// runs failing to schedule their events take no snapshot
+	assert.Nil(repo.CreateRecord(ctx, db.CreateRecordParams{
+		BoxID:     box.ID,
+		Data:      "www.example.com",
+		Container: "hostnames",
+	}))
+	assert.Nil(repo.UpdateBoxQuota(ctx, db.UpdateBoxQuotaParams{
+		EventsQuota: sql.NullInt64{Int64: 2, Valid: true},
+		ID:          box.ID,
+	}))
+
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/automations/"+automation.ID.String()+"/start", nil))
+	assert.Equal(http.StatusBadRequest, rec.Code)
+
+	snapshots, err = repo.ListSnapshots(ctx, box.ID)
+	assert.Nil(err)
+	assert.Len(snapshots, 1)
+}
+
+// automations select their source records by tags and a search term
+func TestAutomationSourceTerm(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "foo",
+		Containers: []string{"hostnames"},
+	})
+	assert.Nil(err)
+
+	for data, tags := range map[string][]string{
+		"api.example.com": {"live"},
+		"dev.example.com": {"live"},
+		"www.example.com": {},
+	} {
+		err = repo.CreateRecord(ctx, db.CreateRecordParams{
+			BoxID:     box.ID,
+			Data:      data,
+			Container: "hostnames",
+			Tags:      tags,
+		})
+		assert.Nil(err)
+	}
+
+	add := func(term string) *httptest.ResponseRecorder {
+		body := `[{"name": "foo", "description": "foo", "command": "echo {data}", "source_container": "hostnames", "source_tags": ["live"], "source_term": "` + term + `", "destination_container": "hostnames", "destination_tags": []}]`
+		req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/automations", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := add("(api")
+	assert.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = add("-dev")
+	assert.Equal(http.StatusOK, rec.Code)
+
+	var created []db.Automation
+	assert.Nil(json.Unmarshal(rec.Body.Bytes(), &created))
+	assert.Len(created, 1)
+	assert.Equal("-dev", created[0].SourceTerm)
+
+	scheduled, err := createAndEnqueue(ctx, dbc, repo, created[0], nil, "", 0)
+	assert.Nil(err)
+	assert.Equal(int64(1), scheduled)
+
+	events, err := repo.ListAutomationEvents(ctx, db.ListAutomationEventsParams{
+		AutomationID: created[0].ID,
+		Limit:        10,
+	})
+	assert.Nil(err)
+	assert.Len(events, 1)
+	assert.Equal("api.example.com", events[0].Data)
+}
+
+// results of an automation are linked to the record they were derived from
+func TestAutomationResultEdges(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "foo",
+		Containers: []string{"hostnames", "urls"},
+	})
+	assert.Nil(err)
+
+	automation, err := repo.CreateAutomation(ctx, db.CreateAutomationParams{
+		BoxID:                box.ID,
+		Name:                 "httpx",
+		SourceContainer:      "hostnames",
+		DestinationContainer: "urls",
+	})
+	assert.Nil(err)
+
+	err = repo.CreateRecord(ctx, db.CreateRecordParams{
+		BoxID:     box.ID,
+		Data:      "example.com",
+		Container: "hostnames",
+	})
+	assert.Nil(err)
+
+	event, err := repo.CreateAutomationEvent(ctx, db.CreateAutomationEventParams{
+		BoxID:        box.ID,
+		AutomationID: automation.ID,
+		Status:       "processing",
+		Data:         "example.com",
+	})
+	assert.Nil(err)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/_results/"+event.ID.String(), strings.NewReader("https://example.com\nhttp://example.com\n"))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(http.StatusOK, rec.Code)
+
+	type Data struct {
+		Records []db.ListRecordChildrenRow `json:"records"`
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames/_children?data=example.com", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	d := new(Data)
+	err = json.Unmarshal(rec.Body.Bytes(), &d)
+	assert.Nil(err)
+	assert.Equal(2, len(d.Records))
+	assert.Equal("http://example.com", d.Records[0].Data)
+	assert.Equal(automation.ID, d.Records[0].AutomationID.UUID)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/urls/_parents?data="+url.QueryEscape("https://example.com"), nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	d = new(Data)
+	err = json.Unmarshal(rec.Body.Bytes(), &d)
+	assert.Nil(err)
+	assert.Equal(1, len(d.Records))
+	assert.Equal("example.com", d.Records[0].Data)
+
+	// the ingest report is kept with the event
+	event, err = repo.GetAutomationEvent(ctx, event.ID)
+	assert.Nil(err)
+
+	report := db.InsertResult{}
+	err = event.Report.AssignTo(&report)
+	assert.Nil(err)
+	assert.Equal(int64(2), report.Received)
+	assert.Equal(int64(2), report.Inserted)
+}
+
+func TestAutomationResultSources(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "foo",
+		Containers: []string{"hostnames", "urls"},
+	})
+	assert.Nil(err)
+
+	automation, err := repo.CreateAutomation(ctx, db.CreateAutomationParams{
+		BoxID:                box.ID,
+		Name:                 "httpx",
+		Command:              "httpx -u {data}",
+		SourceContainer:      "hostnames",
+		DestinationContainer: "urls",
+	})
+	assert.Nil(err)
+
+	event, err := repo.CreateAutomationEvent(ctx, db.CreateAutomationEventParams{
+		BoxID:        box.ID,
+		AutomationID: automation.ID,
+		Status:       "processing",
+		Data:         "example.com",
+	})
+	assert.Nil(err)
+
+	// uploaded before, produced by the automation again
+	req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/urls", strings.NewReader("https://example.com\n"))
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/_results/"+event.ID.String(), strings.NewReader("https://example.com\nhttp://example.com\n"))
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/_results/"+event.ID.String(), nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+
+	type Data struct {
+		Records []db.Record                  `json:"records"`
+		Sources map[string][]db.RecordSource `json:"sources"`
+	}
+
+	d := new(Data)
+	err = json.Unmarshal(rec.Body.Bytes(), &d)
+	assert.Nil(err)
+	assert.Equal(2, len(d.Records))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/urls?sources=true", nil)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	assert.Equal(http.StatusOK, rec.Code)
+
+	d = new(Data)
+	err = json.Unmarshal(rec.Body.Bytes(), &d)
+	assert.Nil(err)
+
+	sources := d.Sources["https://example.com"]
+	assert.Equal(2, len(sources))
+	assert.Equal(db.SourceUpload, sources[0].Kind)
+	assert.False(sources[0].EventID.Valid)
+	assert.Equal(db.SourceAutomation, sources[1].Kind)
+	assert.Equal(event.ID, sources[1].EventID.UUID)
+	assert.Equal("httpx -u {data}", sources[1].Command)
+	assert.Equal("example.com", sources[1].Input)
+
+	assert.Equal(1, len(d.Sources["http://example.com"]))
+}
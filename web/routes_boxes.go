@@ -2,14 +2,18 @@ package web
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hntr/backup"
 	"hntr/db"
+	"hntr/scope"
 	"log"
 	"net/http"
 	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/google/uuid"
+	"github.com/jackc/pgtype"
 	"github.com/jackc/pgx/v4"
 	"github.com/labstack/echo/v4"
 )
@@ -17,6 +21,48 @@ import (
 type UpdateBox struct {
 	Name       string   `json:"name" validate:"required,min=2,max=25"`
 	Containers []string `json:"containers" validate:"required,min=1,max=5,dive,min=2,max=25"`
+
+	// Types optionally sets the record type per container
+	Types map[string]string `json:"types,omitempty" validate:"omitempty,max=5,dive,recordtype"`
+}
+
+// default containers of new boxes
+var defaultContainers = []string{"hostnames", "urls", "events"}
+
+// unknownTypeContainer returns the first container in types which is not one
+// of containers, or an empty string.
+func unknownTypeContainer(types map[string]string, containers []string) string {
+	for container := range types {
+		if !inStringSlice(strings.ToLower(container), containers) {
+			return container
+		}
+	}
+	return ""
+}
+
+// setContainerTypes stores the type of every container in types, other
+// settings are kept.
+func (s *Server) setContainerTypes(ctx context.Context, box db.Box, types map[string]string) error {
+	if len(types) == 0 {
+		return nil
+	}
+
+	all := box.SettingsMap()
+	for container, t := range types {
+		settings := all[strings.ToLower(container)]
+		settings.Type = t
+		all[strings.ToLower(container)] = settings
+	}
+
+	encoded, err := db.EncodeSettings(all)
+	if err != nil {
+		return err
+	}
+
+	return s.repo.UpdateBoxContainerSettings(ctx, db.UpdateBoxContainerSettingsParams{
+		ContainerSettings: encoded,
+		ID:                box.ID,
+	})
 }
 
 func (s *Server) GetBox(c echo.Context) error {
@@ -52,15 +98,61 @@ func (s *Server) GetBox(c echo.Context) error {
 func (s *Server) CreateBox(c echo.Context) error {
 	ctx := context.Background()
 
-	box, err := s.repo.CreateBox(ctx, db.CreateBoxParams{
+	// the default box can be customized with an UpdateBox body
+	boxNew := &UpdateBox{
 		Name:       "Unnamed Box",
-		Containers: []string{"hostnames", "urls", "events"},
+		Containers: defaultContainers,
+	}
+
+	if c.Request().ContentLength > 0 {
+		boxNew = new(UpdateBox)
+		if err := c.Bind(boxNew); err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": "invalid box data",
+			})
+		}
+
+		if err := c.Validate(boxNew); err != nil {
+			errors := err.(validator.ValidationErrors)
+			firstError := errors[0]
+
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("%s: %s", firstError.Field(), validationErrorMsg(firstError)),
+			})
+		}
+	}
+
+	var containersLower []string
+	for _, c := range boxNew.Containers {
+		containersLower = append(containersLower, strings.ToLower(c))
+	}
+
+	if container := unknownTypeContainer(boxNew.Types, containersLower); container != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("Types: %s is not a container of the box", container),
+		})
+	}
+
+	box, err := s.repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       boxNew.Name,
+		Containers: containersLower,
 	})
 	if err != nil {
 		log.Printf("creating box failed: %v", err)
 		return c.JSON(http.StatusInternalServerError, nil)
 	}
 
+	if err := s.setContainerTypes(ctx, box, boxNew.Types); err != nil {
+		log.Printf("setting container types failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	box, err = s.repo.GetBox(ctx, box.ID)
+	if err != nil {
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
 	return c.JSON(http.StatusOK, box)
 }
 
@@ -104,6 +196,35 @@ func (s *Server) UpdateBox(c echo.Context) error {
 		containersLower = append(containersLower, strings.ToLower(c))
 	}
 
+	if container := unknownTypeContainer(boxNew.Types, containersLower); container != "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("Types: %s is not a container of the box", container),
+		})
+	}
+
+	// records of containers left out would be stranded, these have to be
+	// renamed or deleted explicitly
+	for _, container := range box.Containers {
+		if inStringSlice(container, containersLower) {
+			continue
+		}
+
+		count, err := s.repo.CountRecordsByContainer(ctx, db.CountRecordsByContainerParams{
+			BoxID:     box.ID,
+			Container: container,
+		})
+		if err != nil {
+			log.Printf("counting records failed: %v", err)
+			return c.JSON(http.StatusInternalServerError, nil)
+		}
+
+		if count > 0 {
+			return c.JSON(http.StatusConflict, map[string]string{
+				"error": fmt.Sprintf("Containers: %s still holds records, rename or delete it instead", container),
+			})
+		}
+	}
+
 	if err = s.repo.UpdateBox(ctx, db.UpdateBoxParams{
 		ID:         box.ID,
 		Name:       boxNew.Name,
@@ -112,9 +233,137 @@ func (s *Server) UpdateBox(c echo.Context) error {
 		return c.JSON(http.StatusInternalServerError, nil)
 	}
 
+	if err = s.setContainerTypes(ctx, box, boxNew.Types); err != nil {
+		log.Printf("setting container types failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
 	return c.JSON(http.StatusOK, boxNew)
 }
 
+// UpdateContainerSettings replaces the settings of a single container.
+func (s *Server) UpdateContainerSettings(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	container := c.Param("container")
+
+	box, err := s.repo.GetBox(ctx, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if !inStringSlice(container, box.Containers) {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	// fields missing in the request keep their current value
+	settings := box.Settings(container)
+	if err = c.Bind(&settings); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid settings",
+		})
+	}
+
+	if err = c.Validate(settings); err != nil {
+		errors := err.(validator.ValidationErrors)
+		firstError := errors[0]
+
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("%s: %s", firstError.Field(), validationErrorMsg(firstError)),
+		})
+	}
+
+	if _, err := settings.Pipeline(); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	if quota := box.Quotas(s.quotas).Records; settings.Quota > quota {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("Quota: exceeds the quota of the box (%d)", quota),
+		})
+	}
+
+	all := box.SettingsMap()
+	all[container] = settings
+
+	encoded, err := db.EncodeSettings(all)
+	if err != nil {
+		log.Printf("encoding container settings failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := s.repo.UpdateBoxContainerSettings(ctx, db.UpdateBoxContainerSettingsParams{
+		ContainerSettings: encoded,
+		ID:                box.ID,
+	}); err != nil {
+		log.Printf("updating container settings failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, settings)
+}
+
+// UpdateScope replaces the scope rules of a box.
+func (s *Server) UpdateScope(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	box, err := s.repo.GetBox(ctx, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	rules := scope.Rules{}
+	if err = c.Bind(&rules); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid scope",
+		})
+	}
+
+	if _, err := scope.New(rules); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	var encoded pgtype.JSONB
+	if err := encoded.Set(rules); err != nil {
+		log.Printf("encoding scope failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := s.repo.UpdateBoxScope(ctx, db.UpdateBoxScopeParams{
+		Scope: encoded,
+		ID:    box.ID,
+	}); err != nil {
+		log.Printf("updating scope failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, rules)
+}
+
 func (s *Server) DeleteBox(c echo.Context) error {
 	ctx := context.Background()
 
@@ -142,3 +391,70 @@ func (s *Server) DeleteBox(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, nil)
 }
+
+// BackupBox streams an archive of the box, its automations and records. Events
+// are included if the events query param is set.
+func (s *Server) BackupBox(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	if _, err := s.repo.GetBox(ctx, id); err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	_, events := c.QueryParams()["events"]
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "application/gzip")
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"box-%s.hntr.gz\"", id))
+	res.WriteHeader(http.StatusOK)
+
+	// the status is already sent, so errors can only be logged
+	if err := backup.Export(ctx, s.repo, id, res, backup.ExportOptions{Events: events}); err != nil {
+		log.Printf("exporting box failed: %v", err)
+	}
+
+	return nil
+}
+
+// RestoreBox creates a box from an archive written by BackupBox. The box gets
+// a new id, unless the keep_id query param is set.
+func (s *Server) RestoreBox(c echo.Context) error {
+	ctx := context.Background()
+
+	_, keepID := c.QueryParams()["keep_id"]
+
+	box, err := backup.Import(ctx, s.dbPool, c.Request().Body, backup.ImportOptions{
+		KeepID:       keepID,
+		RecordsLimit: int(s.quotas.Records),
+	})
+
+	switch {
+	case errors.Is(err, backup.ErrInvalidArchive):
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	case errors.Is(err, backup.ErrBoxExists):
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": err.Error(),
+		})
+	case errors.Is(err, backup.ErrTooManyRecords):
+		return c.JSON(http.StatusRequestEntityTooLarge, map[string]string{
+			"error": err.Error(),
+		})
+	case err != nil:
+		log.Printf("restoring box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, box)
+}
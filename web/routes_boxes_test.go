@@ -7,8 +7,10 @@ import (
 	"hntr/db"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -38,6 +40,36 @@ func TestCreate(t *testing.T) {
 
 	assert.Equal(http.StatusOK, rec.Code)
 	assert.Equal("Unnamed Box", data.Name)
+	assert.Equal("", data.Settings("hostnames").Type)
+
+	body := `{"name": "typed", "containers": ["IPs"], "types": {"ips": "ip"}}`
+	req = httptest.NewRequest(http.MethodPost, "/api/box/create", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	err = json.Unmarshal(rec.Body.Bytes(), &data)
+	assert.Nil(err)
+
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Equal([]string{"ips"}, data.Containers)
+	assert.Equal("ip", data.Settings("ips").Type)
+
+	body = `{"name": "typed", "containers": ["ips"], "types": {"ips": "foo"}}`
+	req = httptest.NewRequest(http.MethodPost, "/api/box/create", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(http.StatusBadRequest, rec.Code)
+
+	body = `{"name": "typed", "containers": ["ips"], "types": {"hosts": "hostname"}}`
+	req = httptest.NewRequest(http.MethodPost, "/api/box/create", strings.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	rec = httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(http.StatusBadRequest, rec.Code)
 }
 
 func TestGetBox(t *testing.T) {
@@ -116,3 +148,82 @@ func TestUpdateBox(t *testing.T) {
 		assert.Equal([]string{"abc"}, boxUpdated.Containers)
 	})
 }
+
+func TestBackupBox(t *testing.T) {
+	assert := assert.New(t)
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	ctx := context.Background()
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "foo",
+		Containers: []string{"hostnames"},
+	})
+	assert.Nil(err)
+
+	_, err = repo.CreateAutomation(ctx, db.CreateAutomationParams{
+		Name:                 "test",
+		BoxID:                box.ID,
+		Command:              "echo {data}",
+		SourceContainer:      "hostnames",
+		DestinationContainer: "hostnames",
+	})
+	assert.Nil(err)
+
+	for _, data := range []string{"a.example.com", "b.example.com"} {
+		err = repo.CreateRecord(ctx, db.CreateRecordParams{
+			BoxID:     box.ID,
+			Data:      data,
+			Container: "hostnames",
+			Tags:      []string{"foo"},
+		})
+		assert.Nil(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/_backup", nil)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+
+	assert.Equal(http.StatusOK, rec.Code)
+	archive := rec.Body.Bytes()
+
+	t.Run("restore under new id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/box/restore", bytes.NewReader(archive))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Code)
+
+		var restored db.Box
+		err := json.Unmarshal(rec.Body.Bytes(), &restored)
+		assert.Nil(err)
+		assert.NotEqual(box.ID, restored.ID)
+		assert.Equal(box.Containers, restored.Containers)
+
+		count, err := repo.CountRecordsByBox(ctx, restored.ID)
+		assert.Nil(err)
+		assert.Equal(int64(2), count)
+
+		automations, err := repo.ListAutomations(ctx, restored.ID)
+		assert.Nil(err)
+		assert.Equal(1, len(automations))
+	})
+
+	t.Run("reject existing id", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/box/restore?keep_id", bytes.NewReader(archive))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusConflict, rec.Code)
+	})
+
+	t.Run("reject invalid archive", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/box/restore", bytes.NewReader([]byte("foo")))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusBadRequest, rec.Code)
+	})
+}
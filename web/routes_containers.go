@@ -0,0 +1,266 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"hntr/db"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// RenameContainer renames a container of a box. Its records, their relations
+// and sources, its settings and the automations using it move along.
+func (s *Server) RenameContainer(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	container := c.Param("container")
+
+	box, err := s.repo.GetBox(ctx, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if !inStringSlice(container, box.Containers) {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	type RenameContainer struct {
+		Name string `json:"name" validate:"required,min=2,max=25"`
+	}
+
+	rename := new(RenameContainer)
+	if err = c.Bind(rename); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid data",
+		})
+	}
+
+	if err = c.Validate(rename); err != nil {
+		errors := err.(validator.ValidationErrors)
+		firstError := errors[0]
+
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("%s: %s", firstError.Field(), validationErrorMsg(firstError)),
+		})
+	}
+
+	name := strings.ToLower(rename.Name)
+	if inStringSlice(name, box.Containers) {
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": fmt.Sprintf("Name: container %s already exists", name),
+		})
+	}
+
+	// settings are keyed by container
+	settings := box.SettingsMap()
+	if containerSettings, ok := settings[container]; ok {
+		delete(settings, container)
+		settings[name] = containerSettings
+	}
+
+	encoded, err := db.EncodeSettings(settings)
+	if err != nil {
+		log.Printf("encoding container settings failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	tx, err := s.dbPool.Begin(ctx)
+	if err != nil {
+		log.Printf("starting transaction failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	repo := s.repo.WithTx(tx)
+
+	// updating the box first waits for running inserts into it
+	if err := repo.RenameBoxContainer(ctx, db.RenameBoxContainerParams{
+		Container:    container,
+		NewContainer: name,
+		BoxID:        box.ID,
+	}); err != nil {
+		log.Printf("renaming box container failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := repo.UpdateBoxContainerSettings(ctx, db.UpdateBoxContainerSettingsParams{
+		ContainerSettings: encoded,
+		ID:                box.ID,
+	}); err != nil {
+		log.Printf("updating container settings failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	// relations and sources of the records follow by cascade
+	affected, err := repo.RenameRecordContainer(ctx, db.RenameRecordContainerParams{
+		NewContainer: name,
+		BoxID:        box.ID,
+		Container:    container,
+	})
+	if err != nil {
+		log.Printf("renaming record container failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := repo.RenameAutomationContainer(ctx, db.RenameAutomationContainerParams{
+		Container:    container,
+		NewContainer: name,
+		BoxID:        box.ID,
+	}); err != nil {
+		log.Printf("renaming automation container failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	// snapshots are diffed by container
+	if err := repo.RenameSnapshotContainer(ctx, db.RenameSnapshotContainerParams{
+		NewContainer: name,
+		Container:    container,
+		BoxID:        box.ID,
+	}); err != nil {
+		log.Printf("renaming snapshot container failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("committing container rename failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"name":     name,
+		"affected": affected,
+	})
+}
+
+// DeleteContainer removes a container from a box along with its records and
+// the automations using it. As this can not be undone, the number of records
+// to delete has to be confirmed by the confirm query param. Without it, the
+// number is returned.
+func (s *Server) DeleteContainer(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	container := c.Param("container")
+
+	box, err := s.repo.GetBox(ctx, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if !inStringSlice(container, box.Containers) {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	if len(box.Containers) == 1 {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "the last container of a box can not be deleted",
+		})
+	}
+
+	tx, err := s.dbPool.Begin(ctx)
+	if err != nil {
+		log.Printf("starting transaction failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	repo := s.repo.WithTx(tx)
+
+	// updating the box first waits for running inserts into it, so the count
+	// is final
+	if err := repo.DeleteBoxContainer(ctx, db.DeleteBoxContainerParams{
+		Container: container,
+		BoxID:     box.ID,
+	}); err != nil {
+		log.Printf("deleting box container failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	count, err := repo.CountRecordsByContainer(ctx, db.CountRecordsByContainerParams{
+		BoxID:     box.ID,
+		Container: container,
+	})
+	if err != nil {
+		log.Printf("counting records failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	confirm, err := strconv.ParseInt(c.QueryParam("confirm"), 10, 64)
+	if err != nil || confirm != count {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error": fmt.Sprintf("confirm the deletion of %d records", count),
+			"count": count,
+		})
+	}
+
+	settings := box.SettingsMap()
+	delete(settings, container)
+
+	encoded, err := db.EncodeSettings(settings)
+	if err != nil {
+		log.Printf("encoding container settings failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := repo.UpdateBoxContainerSettings(ctx, db.UpdateBoxContainerSettingsParams{
+		ContainerSettings: encoded,
+		ID:                box.ID,
+	}); err != nil {
+		log.Printf("updating container settings failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	deleted, err := repo.DeleteRecordsByContainer(ctx, db.DeleteRecordsByContainerParams{
+		BoxID:     box.ID,
+		Container: container,
+	})
+	if err != nil {
+		log.Printf("deleting records failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	automations, err := repo.DeleteAutomationsByContainer(ctx, db.DeleteAutomationsByContainerParams{
+		BoxID:     box.ID,
+		Container: container,
+	})
+	if err != nil {
+		log.Printf("deleting automations failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("committing container deletion failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"deleted":     deleted,
+		"automations": automations,
+	})
+}
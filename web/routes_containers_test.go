@@ -0,0 +1,109 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"hntr/db"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContainers(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "Testbox",
+		Containers: []string{"hostnames", "urls"},
+	})
+	assert.Nil(err)
+
+	records := []db.CreateRecordParams{
+		{Data: "a.example.com", Container: "hostnames"},
+		{Data: "https://a.example.com", Container: "urls"},
+		{Data: "https://b.example.com", Container: "urls"},
+	}
+	for _, record := range records {
+		record.BoxID = box.ID
+		assert.Nil(repo.CreateRecord(ctx, record))
+	}
+
+	automation, err := repo.CreateAutomation(ctx, db.CreateAutomationParams{
+		BoxID:                box.ID,
+		Name:                 "httpx",
+		SourceContainer:      "hostnames",
+		DestinationContainer: "urls",
+	})
+	assert.Nil(err)
+
+	request := func(method, url, body string, status int) map[string]interface{} {
+		req := httptest.NewRequest(method, "/api/box/"+box.ID.String()+url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(status, rec.Code)
+
+		d := map[string]interface{}{}
+		assert.Nil(json.Unmarshal(rec.Body.Bytes(), &d))
+		return d
+	}
+
+	t.Run("keep containers holding records", func(t *testing.T) {
+		request(http.MethodPut, "", `{"name": "Testbox", "containers": ["hostnames"]}`, http.StatusConflict)
+	})
+
+	t.Run("rename container", func(t *testing.T) {
+		request(http.MethodPut, "/urls/_rename", `{"name": "hostnames"}`, http.StatusConflict)
+
+		d := request(http.MethodPut, "/urls/_rename", `{"name": "Endpoints"}`, http.StatusOK)
+		assert.Equal("endpoints", d["name"])
+		assert.Equal(float64(2), d["affected"])
+
+		b, err := repo.GetBox(ctx, box.ID)
+		assert.Nil(err)
+		assert.Equal([]string{"hostnames", "endpoints"}, b.Containers)
+
+		count, err := repo.CountRecordsByContainer(ctx, db.CountRecordsByContainerParams{
+			BoxID:     box.ID,
+			Container: "endpoints",
+		})
+		assert.Nil(err)
+		assert.Equal(int64(2), count)
+
+		a, err := repo.GetAutomation(ctx, automation.ID)
+		assert.Nil(err)
+		assert.Equal("hostnames", a.SourceContainer)
+		assert.Equal("endpoints", a.DestinationContainer)
+	})
+
+	t.Run("delete container", func(t *testing.T) {
+		d := request(http.MethodDelete, "/endpoints", "", http.StatusConflict)
+		assert.Equal(float64(2), d["count"])
+
+		request(http.MethodDelete, "/endpoints?confirm=1", "", http.StatusConflict)
+
+		d = request(http.MethodDelete, "/endpoints?confirm=2", "", http.StatusOK)
+		assert.Equal(float64(2), d["deleted"])
+		assert.Equal(float64(1), d["automations"])
+
+		b, err := repo.GetBox(ctx, box.ID)
+		assert.Nil(err)
+		assert.Equal([]string{"hostnames"}, b.Containers)
+
+		_, err = repo.GetAutomation(ctx, automation.ID)
+		assert.Equal(pgx.ErrNoRows, err)
+	})
+
+	t.Run("keep the last container", func(t *testing.T) {
+		request(http.MethodDelete, "/hostnames?confirm=1", "", http.StatusBadRequest)
+	})
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"hntr/db"
+	"hntr/domain"
+	"hntr/recordtype"
+	"hntr/search"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// ListDomains groups the hostnames of a container of type hostname matching
+// a term by apex domain and subdomain level. Records which are no hostnames
+// are only counted as other.
+func (s *Server) ListDomains(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	container := c.Param("container")
+
+	box, err := s.repo.GetBox(ctx, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if !inStringSlice(container, box.Containers) {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	if box.Settings(container).Type != recordtype.Hostname {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("container %s is not of type %s", container, recordtype.Hostname),
+		})
+	}
+
+	query, err := search.Parse(c.QueryParam("term"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("invalid term: %v", err),
+		})
+	}
+
+	tree := domain.NewTree()
+
+	err = s.repo.StreamRecordsBySearch(ctx, db.ListRecordsBySearchParams{
+		BoxID:     box.ID,
+		Container: container,
+		Query:     query,
+	}, func(record db.Record) error {
+		tree.Add(record.Data)
+		return nil
+	})
+	if err != nil {
+		log.Printf("listing records failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"domains": tree.Roots(),
+		"other":   tree.Other,
+	})
+}
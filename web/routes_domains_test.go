@@ -0,0 +1,127 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"hntr/db"
+	"hntr/domain"
+	"hntr/recordtype"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListDomains(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "Testbox",
+		Containers: []string{"hostnames", "ips"},
+	})
+	assert.Nil(err)
+
+	settings, err := db.EncodeSettings(map[string]db.ContainerSettings{
+		"hostnames": {Type: recordtype.Hostname},
+	})
+	assert.Nil(err)
+	assert.Nil(repo.UpdateBoxContainerSettings(ctx, db.UpdateBoxContainerSettingsParams{
+		ContainerSettings: settings,
+		ID:                box.ID,
+	}))
+
+	for _, data := range []string{"example.com", "api.dev.example.com", "www.example.co.uk", "10.0.0.1"} {
+		assert.Nil(repo.CreateRecord(ctx, db.CreateRecordParams{
+			Data:      data,
+			BoxID:     box.ID,
+			Container: "hostnames",
+		}))
+	}
+
+	type Data struct {
+		Domains []*domain.Node `json:"domains"`
+		Other   int            `json:"other"`
+	}
+
+	request := func(term string, status int) Data {
+		req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames/_domains?term="+url.QueryEscape(term), nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(status, rec.Code)
+
+		d := Data{}
+		if status == http.StatusOK {
+			assert.Nil(json.Unmarshal(rec.Body.Bytes(), &d))
+		}
+		return d
+	}
+
+	t.Run("group by apex domain", func(t *testing.T) {
+		d := request("", http.StatusOK)
+
+		assert.Equal(1, d.Other)
+		assert.Equal([]*domain.Node{
+			{
+				Name:   "example.co.uk",
+				Count:  1,
+				Record: false,
+				Children: []*domain.Node{
+					{Name: "www.example.co.uk", Count: 1, Record: true},
+				},
+			},
+			{
+				Name:   "example.com",
+				Count:  2,
+				Record: true,
+				Children: []*domain.Node{
+					{
+						Name:  "dev.example.com",
+						Count: 1,
+						Children: []*domain.Node{
+							{Name: "api.dev.example.com", Count: 1, Record: true},
+						},
+					},
+				},
+			},
+		}, d.Domains)
+	})
+
+	t.Run("filter by apex domain", func(t *testing.T) {
+		d := request("apex:example.com", http.StatusOK)
+
+		assert.Equal(0, d.Other)
+		assert.Len(d.Domains, 1)
+		assert.Equal(2, d.Domains[0].Count)
+
+		request("apex:co.uk", http.StatusBadRequest)
+	})
+
+	t.Run("unknown box or container", func(t *testing.T) {
+		for _, path := range []string{
+			"/api/box/" + uuid.New().String() + "/hostnames/_domains",
+			"/api/box/" + box.ID.String() + "/unknown/_domains",
+		} {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			assert.Equal(http.StatusNotFound, rec.Code)
+		}
+	})
+
+	t.Run("container not of type hostname", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/ips/_domains", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusBadRequest, rec.Code)
+	})
+}
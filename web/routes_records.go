@@ -1,9 +1,13 @@
 package web
 
 import (
+	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"hntr/db"
+	"hntr/search"
 	"log"
 	"net/http"
 	"strconv"
@@ -26,7 +30,12 @@ func (s *Server) ListRecords(c echo.Context) error {
 	container := c.Param("container")
 	limit, _ := strconv.Atoi(c.QueryParam("limit"))
 	offset, _ := strconv.Atoi(c.QueryParam("offset"))
-	searchword, tags := parseTerm(c.QueryParam("term"))
+	query, err := search.Parse(c.QueryParam("term"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("invalid term: %v", err),
+		})
+	}
 
 	if limit < 1 || limit > LIMIT_MAX {
 		limit = LIMIT_MAX
@@ -34,36 +43,227 @@ func (s *Server) ListRecords(c echo.Context) error {
 
 	// TODO: retrieve box and check if container exists
 
-	params := db.ListRecordsByBoxFilterPaginatedParams{
+	params := db.ListRecordsBySearchParams{
 		BoxID:     id,
 		Container: container,
-		Data:      "%" + searchword + "%",
-		Column3:   tags,
+		Query:     query,
 		Limit:     int32(limit),
 		Offset:    int32(offset),
 	}
 
-	paramsCount := db.CountRecordsByBoxFilterParams{
-		BoxID:     id,
-		Container: container,
-		Data:      "%" + searchword + "%",
-		Column3:   tags,
+	if c.QueryParam("cursor") != "" {
+		cursor, err := db.DecodeRecordCursor(c.QueryParam("cursor"))
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": err.Error(),
+			})
+		}
+
+		params.After = &cursor
 	}
 
-	records, err := s.repo.ListRecordsByBoxFilterPaginated(ctx, params)
+	records, err := s.repo.ListRecordsBySearch(ctx, params)
 	if err != nil && err != pgx.ErrNoRows {
 		log.Printf("listing boxes failed: %v", err)
 		return c.JSON(http.StatusInternalServerError, nil)
 	}
 
-	count, err := s.repo.CountRecordsByBoxFilter(ctx, paramsCount)
-	if err != nil && err != pgx.ErrNoRows {
-		log.Printf("listing boxes failed: %v", err)
+	// a full page may be followed by more records
+	var next interface{}
+	if len(records) == limit {
+		next = db.NewRecordCursor(records[len(records)-1]).Encode()
+	}
+
+	response := map[string]interface{}{
+		"records": records,
+		"cursor":  next,
+	}
+
+	// sources are only loaded on request, grouped by the data of the record
+	if c.QueryParam("sources") == "true" {
+		data := make([]string, len(records))
+		for i, record := range records {
+			data[i] = record.Data
+		}
+
+		sources, err := s.repo.ListRecordSources(ctx, db.ListRecordSourcesParams{
+			BoxID:     id,
+			Container: container,
+			Column3:   data,
+		})
+		if err != nil {
+			log.Printf("listing record sources failed: %v", err)
+			return c.JSON(http.StatusInternalServerError, nil)
+		}
+
+		bySource := make(map[string][]db.RecordSource)
+		for _, source := range sources {
+			bySource[source.Data] = append(bySource[source.Data], source)
+		}
+		response["sources"] = bySource
+	}
+
+	// paginating clients can skip counting on every page and use
+	// CountFilteredRecords instead
+	if c.QueryParam("count") != "false" {
+		paramsCount := db.CountRecordsBySearchParams{
+			BoxID:     id,
+			Container: container,
+			Query:     query,
+		}
+
+		count, err := s.repo.CountRecordsBySearch(ctx, paramsCount)
+		if err != nil && err != pgx.ErrNoRows {
+			log.Printf("listing boxes failed: %v", err)
+			return c.JSON(http.StatusInternalServerError, nil)
+		}
+
+		response["count"] = count
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// CountFilteredRecords counts the records of a container matching a term.
+func (s *Server) CountFilteredRecords(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	query, err := search.Parse(c.QueryParam("term"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("invalid term: %v", err),
+		})
+	}
+
+	count, err := s.repo.CountRecordsBySearch(ctx, db.CountRecordsBySearchParams{
+		BoxID:     id,
+		Container: c.Param("container"),
+		Query:     query,
+	})
+	if err != nil {
+		log.Printf("counting records failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"count": count,
+	})
+}
+
+// ExportRecords streams all records of a container matching a term. Unlike
+// ListRecords the result is not limited, as records are written while they
+// are read from the database.
+func (s *Server) ExportRecords(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	container := c.Param("container")
+
+	name := c.QueryParam("format")
+	if name == "" {
+		name = "txt"
+	}
+
+	format, ok := exportFormats[name]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid format, use one of txt, csv or jsonl",
+		})
+	}
+
+	query, err := search.Parse(c.QueryParam("term"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("invalid term: %v", err),
+		})
+	}
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, format.contentType)
+	res.Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", container+"."+format.extension))
+	res.WriteHeader(http.StatusOK)
+
+	buf := bufio.NewWriter(res)
+	writer := format.writer(buf)
+
+	params := db.ListRecordsBySearchParams{
+		BoxID:     id,
+		Container: container,
+		Query:     query,
+	}
+
+	// the status is already sent, so errors can only be logged
+	err = s.repo.StreamRecordsBySearch(ctx, params, writer.Write)
+	if err != nil {
+		log.Printf("exporting records failed: %v", err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		log.Printf("exporting records failed: %v", err)
+	}
+
+	if err := buf.Flush(); err != nil {
+		log.Printf("exporting records failed: %v", err)
+	}
+
+	return nil
+}
+
+// ListRecordChildren lists the records derived from the record given by the
+// data query param, e.g. the urls an automation found for a hostname.
+func (s *Server) ListRecordChildren(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	records, err := s.repo.ListRecordChildren(ctx, db.ListRecordChildrenParams{
+		BoxID:           id,
+		SourceContainer: c.Param("container"),
+		SourceData:      c.QueryParam("data"),
+	})
+	if err != nil {
+		log.Printf("listing record children failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"records": records,
+	})
+}
+
+// ListRecordParents lists the records the record given by the data query
+// param was derived from.
+func (s *Server) ListRecordParents(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	records, err := s.repo.ListRecordParents(ctx, db.ListRecordParentsParams{
+		BoxID:           id,
+		TargetContainer: c.Param("container"),
+		TargetData:      c.QueryParam("data"),
+	})
+	if err != nil {
+		log.Printf("listing record parents failed: %v", err)
 		return c.JSON(http.StatusInternalServerError, nil)
 	}
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
-		"count":   count,
 		"records": records,
 	})
 }
@@ -94,12 +294,6 @@ func (s *Server) AddRecords(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, nil)
 	}
 
-	count, err := s.repo.CountRecordsByBox(ctx, id)
-	if err != nil {
-		log.Printf("getting box count failed: %v", err)
-		return c.JSON(http.StatusInternalServerError, box)
-	}
-
 	// split tags
 	tags := strings.FieldsFunc(c.QueryParam("tags"), func(c rune) bool {
 		return c == ','
@@ -126,25 +320,93 @@ func (s *Server) AddRecords(c echo.Context) error {
 		})
 	}
 
-	updateDuplicate := false
+	attributes, err := parseAttributes(c.QueryParam("attrs"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": err.Error(),
+		})
+	}
+
+	duplicateMode := db.DuplicateIgnore
 	if c.QueryParam("update") != "" {
-		updateDuplicate = true
+		duplicateMode = db.DuplicateReplace
+	} else if c.QueryParam("merge") != "" {
+		duplicateMode = db.DuplicateMerge
 	}
 
-	affected := db.RecordsBatchInsert(
-		ctx,
-		s.dbPool,
-		c.Request().Body,
-		id,
-		container,
-		tags,
-		int64(s.recordsLimit)-count-1,
-		updateDuplicate,
-	)
+	// newline delimited json carries tags and attributes per line
+	var reader db.RecordReader = db.NewLineReader(c.Request().Body)
+	var ndjson *ndjsonReader
 
-	return c.JSON(http.StatusOK, map[string]interface{}{
-		"changed": affected,
-	})
+	if isNDJSON(c.Request().Header.Get(echo.HeaderContentType)) {
+		ndjson = newNDJSONReader(c.Request().Body, tags, c.Validate)
+		reader = ndjson
+	}
+
+	opts := db.InsertOptions{
+		BoxID:         id,
+		Container:     container,
+		Tags:          tags,
+		Attributes:    attributes,
+		Quota:         box.Quotas(s.quotas).Records,
+		DuplicateMode: duplicateMode,
+		Source:        &db.Source{Kind: db.SourceUpload},
+	}
+
+	if err := box.Apply(container, &opts); err != nil {
+		log.Printf("loading box settings failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	// like `anew`, answer with all lines which were not stored before. The
+	// lines are only known once the insert is committed, along with the
+	// quota headers.
+	anew := c.QueryParam("anew") != ""
+
+	if anew {
+		opts.OnCommit = func(result db.InsertResult) {
+			setQuotaHeaders(c, result.Usage)
+			c.Response().Header().Set(echo.HeaderContentType, echo.MIMETextPlainCharsetUTF8)
+			c.Response().WriteHeader(http.StatusOK)
+		}
+		opts.OnInserted = func(data string) {
+			fmt.Fprintln(c.Response(), data)
+		}
+	}
+
+	result, err := db.RecordsBatchInsert(ctx, s.dbPool, reader, opts)
+
+	// in anew mode the answer is written as soon as the records are stored,
+	// an error can only occur while listing them then
+	if c.Response().Committed {
+		if err != nil {
+			log.Printf("listing inserted records failed: %v", err)
+		}
+		return nil
+	}
+
+	setQuotaHeaders(c, result.Usage)
+
+	if err != nil {
+		return insertError(c, result, err)
+	}
+
+	// the report of the insert, extended by the lines the ndjson reader
+	// rejected before
+	type Response struct {
+		db.InsertResult
+		Errors []string `json:"errors,omitempty"`
+	}
+
+	response := Response{InsertResult: result}
+
+	if ndjson != nil {
+		response.Received += int64(ndjson.rejected)
+		response.Rejected += int64(ndjson.rejected)
+		response.Errors = ndjson.errors
+	}
+
+	return c.JSON(http.StatusOK, response)
 }
 
 func (s *Server) CountRecords(c echo.Context) error {
@@ -155,6 +417,16 @@ func (s *Server) CountRecords(c echo.Context) error {
 		return c.JSON(http.StatusNotFound, nil)
 	}
 
+	box, err := s.repo.GetBox(ctx, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
 	count, err := s.repo.CountRecordsByBox(ctx, id)
 	if err != nil {
 		log.Printf("listing boxes failed: %v", err)
@@ -163,7 +435,7 @@ func (s *Server) CountRecords(c echo.Context) error {
 
 	return c.JSON(http.StatusOK, map[string]interface{}{
 		"count": count,
-		"limit": s.recordsLimit,
+		"limit": box.Quotas(s.quotas).Records,
 	})
 }
 
@@ -198,6 +470,7 @@ func (s *Server) UpdateRecords(c echo.Context) error {
 	type UpdateRecords struct {
 		Records []string `json:"records" validate:"required,min=1"`
 		Tags    []string `json:"tags" validate:"required,max=10,dive,max=50"`
+		Action  string   `json:"action" validate:"omitempty,oneof=replace add remove"`
 	}
 
 	updateRecords := new(UpdateRecords)
@@ -217,13 +490,51 @@ func (s *Server) UpdateRecords(c echo.Context) error {
 		})
 	}
 
-	if err = s.repo.UpdateRecordTags(ctx, db.UpdateRecordTagsParams{
-		Tags:      cleanTags(updateRecords.Tags),
-		BoxID:     box.ID,
-		Container: container,
-		Column4:   updateRecords.Records,
-	}); err != nil {
-		log.Printf("getting box failed: %v", err)
+	switch updateRecords.Action {
+	case "add":
+		var exceeding int64
+		exceeding, err = s.repo.CountRecordsExceedingTags(ctx, db.CountRecordsExceedingTagsParams{
+			Column1:   cleanTags(updateRecords.Tags),
+			BoxID:     box.ID,
+			Container: container,
+			Column4:   updateRecords.Records,
+			TagsMax:   TAGS_MAX,
+		})
+		if err != nil {
+			log.Printf("counting record tags failed: %v", err)
+			return c.JSON(http.StatusInternalServerError, nil)
+		}
+
+		if exceeding > 0 {
+			return c.JSON(http.StatusNotAcceptable, map[string]string{
+				"error": fmt.Sprintf("too many tags: %d records would have more than %d. MAX_TAGS=%v", exceeding, TAGS_MAX, TAGS_MAX),
+			})
+		}
+
+		err = s.repo.AddRecordTags(ctx, db.AddRecordTagsParams{
+			Column1:   cleanTags(updateRecords.Tags),
+			BoxID:     box.ID,
+			Container: container,
+			Column4:   updateRecords.Records,
+		})
+	case "remove":
+		err = s.repo.RemoveRecordTags(ctx, db.RemoveRecordTagsParams{
+			Column1:   cleanTags(updateRecords.Tags),
+			BoxID:     box.ID,
+			Container: container,
+			Column4:   updateRecords.Records,
+		})
+	default:
+		err = s.repo.UpdateRecordTags(ctx, db.UpdateRecordTagsParams{
+			Tags:      cleanTags(updateRecords.Tags),
+			BoxID:     box.ID,
+			Container: container,
+			Column4:   updateRecords.Records,
+		})
+	}
+
+	if err != nil {
+		log.Printf("updating record tags failed: %v", err)
 		return c.JSON(http.StatusInternalServerError, box)
 	}
 
@@ -291,30 +602,140 @@ func (s *Server) DeleteRecords(c echo.Context) error {
 	return c.JSON(http.StatusOK, nil)
 }
 
-func parseTerm(term string) (string, []string) {
+// BulkRecords adds, removes or replaces tags of, or deletes, all records of a
+// container matching a term, without sending the records themselves.
+func (s *Server) BulkRecords(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	container := c.Param("container")
+
+	box, err := s.repo.GetBox(ctx, id)
+	if err == pgx.ErrNoRows {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	if err != nil {
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if !inStringSlice(container, box.Containers) {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	type BulkRecords struct {
+		Term   string   `json:"term" validate:"required"`
+		Action string   `json:"action" validate:"required,oneof=add remove replace delete"`
+		Tags   []string `json:"tags" validate:"max=10,dive,max=50"`
+		DryRun bool     `json:"dry_run"`
+	}
+
+	bulk := new(BulkRecords)
+	if err = c.Bind(bulk); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid data",
+		})
+	}
+
+	if err = c.Validate(bulk); err != nil {
+		errors := err.(validator.ValidationErrors)
+
+		firstError := errors[0]
+
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("%s: %s", firstError.Field(), validationErrorMsg(firstError)),
+		})
+	}
 
-	tags := make([]string, 0)
-	searchword := ""
+	tags := cleanTags(bulk.Tags)
+	if len(tags) == 0 && (bulk.Action == db.BulkAddTags || bulk.Action == db.BulkRemoveTags) {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Tags: This field is required",
+		})
+	}
 
-	// split terms by space
-	keywords := strings.FieldsFunc(term, func(c rune) bool {
-		return c == ' '
+	query, err := search.Parse(bulk.Term)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("invalid term: %v", err),
+		})
+	}
+
+	// a bulk action never applies to the whole container by accident
+	if query.Empty() {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Term: This field is required",
+		})
+	}
+
+	affected, err := s.repo.BulkRecordsBySearch(ctx, db.BulkRecordsBySearchParams{
+		BoxID:     box.ID,
+		Container: container,
+		Query:     query,
+		Action:    bulk.Action,
+		Tags:      tags,
+		DryRun:    bulk.DryRun,
 	})
+	if errors.Is(err, db.ErrTooManyTags) {
+		return c.JSON(http.StatusNotAcceptable, map[string]string{
+			"error": fmt.Sprintf("%v. MAX_TAGS=%v", err, TAGS_MAX),
+		})
+	}
 
-	for _, k := range keywords {
-		if strings.HasPrefix(k, "tag:") {
-			tag := k[4:]
-			if tag == "" {
-				continue
-			}
+	if err != nil {
+		log.Printf("bulk %s of records failed: %v", bulk.Action, err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
 
-			tags = append(tags, tag)
-		} else {
-			searchword = k
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"affected": affected,
+		"dry_run":  bulk.DryRun,
+	})
+}
+
+// parseAttributes parses a list of attributes in the form of
+// `key=value,key2=value2` as passed via the `attrs` query parameter.
+func parseAttributes(raw string) (map[string]interface{}, error) {
+	attributes := make(map[string]interface{})
+
+	pairs := strings.FieldsFunc(raw, func(c rune) bool {
+		return c == ','
+	})
+
+	if len(pairs) > ATTRIBUTES_MAX {
+		return nil, fmt.Errorf("too many attributes. ATTRIBUTES_MAX=%v", ATTRIBUTES_MAX)
+	}
+
+	for _, pair := range pairs {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid attribute %q, expected key=value", pair)
 		}
+
+		if len(kv[0]) > 50 || len(kv[1]) > 250 {
+			return nil, fmt.Errorf("attribute %q is too long", kv[0])
+		}
+
+		attributes[kv[0]] = parseAttributeValue(kv[1])
+	}
+
+	return attributes, nil
+}
+
+// parseAttributeValue keeps numbers, booleans and other JSON literals typed,
+// everything else is stored as a plain string.
+func parseAttributeValue(value string) interface{} {
+	var parsed interface{}
+	if err := json.Unmarshal([]byte(value), &parsed); err != nil {
+		return value
 	}
 
-	return searchword, tags
+	return parsed
 }
 
 func cleanTags(tags []string) []string {
@@ -2,14 +2,21 @@ package web
 
 import (
 	"context"
+	"database/sql"
+	"encoding/csv"
 	"encoding/json"
 	"fmt"
 	"hntr/db"
+	"hntr/search"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"testing"
 
+	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -100,6 +107,185 @@ func TestGetRecords(t *testing.T) {
 
 		assert.Len(d.Records, 1)
 	})
+
+	t.Run("list by query", func(t *testing.T) {
+		type Data struct {
+			Records []db.Record `json:"records"`
+			Count   int         `json:"count"`
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames?term="+url.QueryEscape("foo_ (foo_1 OR foo_2 OR tag:single_tag) -data:foo_2"), nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(rec.Result().StatusCode, 200)
+
+		d := new(Data)
+		err = json.Unmarshal(rec.Body.Bytes(), &d)
+		assert.Nil(err)
+
+		assert.Equal(2, d.Count)
+		assert.Equal("foo_last_added", d.Records[0].Data)
+		assert.Equal("foo_1", d.Records[1].Data)
+	})
+
+	t.Run("reject invalid query", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames?term="+url.QueryEscape("(tag:a"), nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusBadRequest, rec.Result().StatusCode)
+	})
+
+	t.Run("list by last seen", func(t *testing.T) {
+		type Data struct {
+			Records []db.Record `json:"records"`
+			Count   int         `json:"count"`
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames?term=last_seen:<30d", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(rec.Result().StatusCode, 200)
+
+		d := new(Data)
+		err = json.Unmarshal(rec.Body.Bytes(), &d)
+		assert.Nil(err)
+		assert.Equal(0, d.Count)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames?term=last_seen:>1d", nil)
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		d = new(Data)
+		err = json.Unmarshal(rec.Body.Bytes(), &d)
+		assert.Nil(err)
+		assert.Equal(11, d.Count)
+	})
+
+	t.Run("paginate with cursor", func(t *testing.T) {
+		type Data struct {
+			Records []db.Record `json:"records"`
+			Cursor  string      `json:"cursor"`
+		}
+
+		request := func(query string) *Data {
+			req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames?count=false&"+query, nil)
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			assert.Equal(rec.Result().StatusCode, 200)
+
+			d := new(Data)
+			assert.Nil(json.Unmarshal(rec.Body.Bytes(), &d))
+			return d
+		}
+
+		seen := map[string]bool{}
+		order := []string{}
+		cursor := ""
+
+		for page := 0; page < 3; page++ {
+			d := request("limit=5&cursor=" + cursor)
+
+			for _, r := range d.Records {
+				assert.False(seen[r.Data])
+				seen[r.Data] = true
+				order = append(order, r.Data)
+			}
+
+			cursor = d.Cursor
+		}
+
+		assert.Equal(11, len(seen))
+		assert.Equal("", cursor)
+
+		// pages follow the order of offset listing
+		offsetOrder := []string{}
+		for _, r := range request("").Records {
+			offsetOrder = append(offsetOrder, r.Data)
+		}
+		assert.Equal(offsetOrder, order)
+	})
+
+	t.Run("reject invalid cursor", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames?cursor=foo", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(rec.Result().StatusCode, 400)
+	})
+}
+
+func TestExportRecords(t *testing.T) {
+	assert := assert.New(t)
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(context.Background(), db.CreateBoxParams{
+		Name:       "Testbox",
+		Containers: []string{"hostnames"},
+	})
+	assert.Nil(err)
+
+	for i := 0; i < 3; i++ {
+		err = repo.CreateRecord(context.Background(), db.CreateRecordParams{
+			BoxID:     box.ID,
+			Data:      fmt.Sprintf("foo_%v", i),
+			Container: "hostnames",
+			Tags:      []string{"a", "b"},
+		})
+		assert.Nil(err)
+	}
+
+	t.Run("export text", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames/_export?term=-data:foo_0", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(rec.Result().StatusCode, 200)
+		assert.Equal("foo_2\nfoo_1\n", rec.Body.String())
+	})
+
+	t.Run("export csv", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames/_export?format=csv", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(rec.Result().StatusCode, 200)
+
+		rows, err := csv.NewReader(rec.Body).ReadAll()
+		assert.Nil(err)
+		assert.Equal(4, len(rows))
+		assert.Equal([]string{"data", "tags", "created_at"}, rows[0])
+		assert.Equal("a,b", rows[1][1])
+	})
+
+	t.Run("export jsonl", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames/_export?format=jsonl", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(rec.Result().StatusCode, 200)
+
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		assert.Equal(3, len(lines))
+
+		record := db.Record{}
+		err = json.Unmarshal([]byte(lines[0]), &record)
+		assert.Nil(err)
+		assert.Equal("foo_2", record.Data)
+	})
+
+	t.Run("reject unknown format", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames/_export?format=xml", nil)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(rec.Result().StatusCode, 400)
+	})
 }
 
 func TestAddRecords(t *testing.T) {
@@ -151,8 +337,744 @@ func TestAddRecords(t *testing.T) {
 
 		assert.Len(records, 2)
 	})
+
+	t.Run("add a record with attributes", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/urls?attrs=status=200,title=Login", strings.NewReader("https://foo.example.com"))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(rec.Result().StatusCode, 200)
+
+		req = httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/urls?term=attr:status=200", nil)
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(rec.Result().StatusCode, 200)
+
+		type Data struct {
+			Records []db.Record `json:"records"`
+			Count   int         `json:"count"`
+		}
+		d := new(Data)
+		err = json.Unmarshal(rec.Body.Bytes(), &d)
+		assert.Nil(err)
+
+		assert.Len(d.Records, 1)
+		assert.Equal("https://foo.example.com", d.Records[0].Data)
+
+		attributes := map[string]interface{}{}
+		assert.Nil(d.Records[0].Attributes.AssignTo(&attributes))
+		assert.Equal(float64(200), attributes["status"])
+		assert.Equal("Login", attributes["title"])
+	})
+
+	t.Run("add ndjson records", func(t *testing.T) {
+		body := strings.Join([]string{
+			`{"data": "https://a.example.com", "tags": ["status:200"], "attrs": {"status": 200}}`,
+			`{"data": "https://b.example.com"`,
+			`{"data": "https://c.example.com", "tags": ["1", "2", "3", "4", "5", "6", "7", "8", "9", "10"]}`,
+			`{"data": "https://d.example.com", "attrs": {"title": "Admin"}}`,
+		}, "\n")
+
+		req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/urls?tags=source:httpx", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-ndjson")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(rec.Result().StatusCode, 200)
+
+		type Response struct {
+			Changed  int      `json:"changed"`
+			Rejected int      `json:"rejected"`
+			Errors   []string `json:"errors"`
+		}
+		r := new(Response)
+		err = json.Unmarshal(rec.Body.Bytes(), &r)
+		assert.Nil(err)
+
+		assert.Equal(2, r.Changed)
+		assert.Equal(2, r.Rejected)
+		assert.Equal("line 2: invalid json", r.Errors[0])
+
+		records, err := repo.ListRecordsByBoxFilter(context.Background(), db.ListRecordsByBoxFilterParams{
+			BoxID:     box.ID,
+			Container: "urls",
+			Column3:   []string{"source:httpx", "status:200"},
+			Data:      "%%",
+		})
+		assert.Nil(err)
+
+		assert.Len(records, 1)
+		assert.Equal("https://a.example.com", records[0].Data)
+	})
+
+	t.Run("track duplicate records as seen", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/hostnames", strings.NewReader("seen.example.com"))
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+
+			assert.Equal(rec.Result().StatusCode, 200)
+
+			result := db.InsertResult{}
+			assert.Nil(json.Unmarshal(rec.Body.Bytes(), &result))
+			assert.Equal(int64(1-i), result.Affected)
+		}
+
+		records, err := repo.ListRecordsByBoxFilter(context.Background(), db.ListRecordsByBoxFilterParams{
+			BoxID:     box.ID,
+			Container: "hostnames",
+			Column3:   []string{},
+			Data:      "seen.example.com",
+		})
+		assert.Nil(err)
+
+		assert.Len(records, 1)
+		assert.Equal(int32(2), records[0].SeenCount)
+		assert.True(records[0].LastSeenAt.After(records[0].CreatedAt))
+	})
+
+	t.Run("return only new records", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/hostnames", strings.NewReader("anew1.example.com\nanew2.example.com"))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		assert.Equal(rec.Result().StatusCode, 200)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/hostnames?anew=1", strings.NewReader("anew2.example.com\nanew3.example.com\nanew1.example.com"))
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(rec.Result().StatusCode, 200)
+		assert.Equal("anew3.example.com\n", rec.Body.String())
+	})
+
+	t.Run("merge tags of duplicate records", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/hostnames?tags=source:amass", strings.NewReader("a.example.com"))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		assert.Equal(rec.Result().StatusCode, 200)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/hostnames?tags=source:subfinder&merge=1", strings.NewReader("a.example.com"))
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+		assert.Equal(rec.Result().StatusCode, 200)
+
+		records, err := repo.ListRecordsByBoxFilter(context.Background(), db.ListRecordsByBoxFilterParams{
+			BoxID:     box.ID,
+			Container: "hostnames",
+			Column3:   []string{},
+			Data:      "a.example.com",
+		})
+		assert.Nil(err)
+
+		assert.Len(records, 1)
+		assert.Equal([]string{"source:amass", "source:subfinder"}, records[0].Tags)
+	})
+
+	t.Run("cap merged tags", func(t *testing.T) {
+		post := func(tags string) {
+			req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/hostnames?merge=1&tags="+tags, strings.NewReader("capped.example.com\ncapped.example.com"))
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			assert.Equal(http.StatusOK, rec.Code)
+		}
+
+		post("a,b,c,d,e,f,g,h")
+		post("i,j,k,l")
+
+		records, err := repo.ListRecordsByBoxFilter(context.Background(), db.ListRecordsByBoxFilterParams{
+			BoxID:     box.ID,
+			Container: "hostnames",
+			Column3:   []string{},
+			Data:      "capped.example.com",
+		})
+		assert.Nil(err)
+
+		assert.Len(records, 1)
+		assert.Equal([]string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j"}, records[0].Tags)
+	})
+
+	t.Run("reject malformed attributes", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/urls?attrs=status", strings.NewReader("https://bar.example.com"))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusBadRequest, rec.Result().StatusCode)
+	})
+
+	t.Run("normalize records", func(t *testing.T) {
+		settings := `{"normalizers": ["lowercase", "trim_dot", "strip_wildcard", "drop_empty"]}`
+		req := httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/urls/_settings", strings.NewReader(settings))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+
+		body := "normalized.example.com\nNormalized.Example.com.\n*.normalized.example.com\n.\n\n"
+		req = httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/urls", strings.NewReader(body))
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+
+		result := db.InsertResult{}
+		err = json.Unmarshal(rec.Body.Bytes(), &result)
+		assert.Nil(err)
+		assert.Equal(db.InsertResult{
+			Received:   5,
+			Empty:      1,
+			Inserted:   1,
+			Duplicates: 2,
+			Affected:   1,
+			Dropped:    1,
+			Merged:     2,
+			Usage:      result.Usage,
+			Samples:    []db.Skipped{{Line: ".", Reason: "dropped by normalizer"}},
+		}, result)
+	})
+
+	t.Run("reject records not matching the container type", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/urls/_settings", strings.NewReader(`{"type": "url"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/urls", strings.NewReader("https://example.com/typed\n[ERR] no results\n"+strings.Repeat("ä", 250)))
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		result := db.InsertResult{}
+		err = json.Unmarshal(rec.Body.Bytes(), &result)
+		assert.Nil(err)
+		assert.Equal(int64(1), result.Affected)
+		assert.Equal(int64(2), result.Rejected)
+		assert.Equal([]db.Skipped{
+			{Line: "[ERR] no results", Reason: "not of type url"},
+			{Line: strings.Repeat("ä", 200) + "...", Reason: "not of type url"},
+		}, result.Samples)
+	})
+
+	t.Run("reject unknown record type", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/urls/_settings", strings.NewReader(`{"type": "foo"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusBadRequest, rec.Result().StatusCode)
+	})
+
+	t.Run("tag records by scope", func(t *testing.T) {
+		rules := `{"include": ["*.example.com"], "exclude": ["*.corp.example.com"]}`
+		req := httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/_scope", strings.NewReader(rules))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+
+		// in untyped containers scope rules only apply to hostnames, urls and
+		// the like, not to free text
+		req = httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/hostnames", strings.NewReader("untyped.corp.example.com\n[INF] free text\n"))
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		result := db.InsertResult{}
+		err = json.Unmarshal(rec.Body.Bytes(), &result)
+		assert.Nil(err)
+		assert.Equal(int64(2), result.Affected)
+		assert.Equal(int64(1), result.OutOfScope)
+
+		req = httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/hostnames/_settings", strings.NewReader(`{"type": "hostname"}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+
+		req = httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/hostnames", strings.NewReader("www.scoped.example.com\nvpn.corp.example.com\n"))
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		result = db.InsertResult{}
+		err = json.Unmarshal(rec.Body.Bytes(), &result)
+		assert.Nil(err)
+		assert.Equal(int64(2), result.Affected)
+		assert.Equal(int64(1), result.OutOfScope)
+
+		query, _ := search.Parse("tag:out_of_scope")
+		records, err := repo.ListRecordsBySearch(context.Background(), db.ListRecordsBySearchParams{
+			BoxID:     box.ID,
+			Container: "hostnames",
+			Query:     query,
+		})
+		assert.Nil(err)
+		data := []string{}
+		for _, r := range records {
+			data = append(data, r.Data)
+		}
+		assert.ElementsMatch([]string{"untyped.corp.example.com", "vpn.corp.example.com"}, data)
+
+		query, _ = search.Parse("tag:in_scope")
+		records, err = repo.ListRecordsBySearch(context.Background(), db.ListRecordsBySearchParams{
+			BoxID:     box.ID,
+			Container: "hostnames",
+			Query:     query,
+		})
+		assert.Nil(err)
+		assert.Equal(1, len(records))
+		assert.Equal("www.scoped.example.com", records[0].Data)
+
+		// remove the scope and type again for other tests
+		req = httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/_scope", strings.NewReader(`{}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+
+		req = httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/hostnames/_settings", strings.NewReader(`{"type": ""}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec = httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+	})
+
+	t.Run("reject invalid scope", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/_scope", strings.NewReader(`{"include": ["10.0.0.0/33"]}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusBadRequest, rec.Result().StatusCode)
+	})
+
+	t.Run("add long records", func(t *testing.T) {
+		long := "long-" + strings.Repeat("a", 3000)
+		tooLong := strings.Repeat("b", db.RecordDataMax+1)
+		exceedingBuffer := strings.Repeat("c", 100*1024)
+
+		body := long + "\n" + tooLong + "\n" + exceedingBuffer + "\n" + long + "\nafter.example.com\n"
+		req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/hostnames", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+
+		result := db.InsertResult{}
+		err = json.Unmarshal(rec.Body.Bytes(), &result)
+		assert.Nil(err)
+		assert.Equal(int64(2), result.Affected)
+		assert.Equal(int64(2), result.TooLong)
+
+		records, err := repo.ListRecordsByBoxFilter(context.Background(), db.ListRecordsByBoxFilterParams{
+			BoxID:     box.ID,
+			Container: "hostnames",
+			Column3:   []string{},
+			Data:      "long-%",
+		})
+		assert.Nil(err)
+		assert.Len(records, 1)
+		assert.Equal(long, records[0].Data)
+		assert.Equal(int32(2), records[0].SeenCount)
+	})
+
+	t.Run("enforce quotas", func(t *testing.T) {
+		quotaBox, err := repo.CreateBox(context.Background(), db.CreateBoxParams{
+			Name:       "Quotabox",
+			Containers: []string{"hostnames"},
+		})
+		assert.Nil(err)
+
+		request := func(method, url, body string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(method, "/api/box/"+quotaBox.ID.String()+url, strings.NewReader(body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, req)
+			return rec
+		}
+
+		rec := request(http.MethodPut, "/hostnames/_settings", `{"quota": 2000}`)
+		assert.Equal(http.StatusBadRequest, rec.Result().StatusCode)
+
+		rec = request(http.MethodPut, "/hostnames/_settings", `{"quota": 2}`)
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+
+		rec = request(http.MethodPost, "/hostnames", "a.example.com\nb.example.com\nc.example.com")
+		assert.Equal(http.StatusRequestEntityTooLarge, rec.Result().StatusCode)
+		assert.Equal("0", rec.Header().Get("X-Quota-Used"))
+
+		count, err := repo.CountRecordsByBox(context.Background(), quotaBox.ID)
+		assert.Nil(err)
+		assert.Equal(int64(0), count)
+
+		rec = request(http.MethodPost, "/hostnames", "a.example.com\nb.example.com")
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+		assert.Equal("1000", rec.Header().Get("X-Quota-Limit"))
+		assert.Equal("2", rec.Header().Get("X-Quota-Used"))
+		assert.Equal("998", rec.Header().Get("X-Quota-Remaining"))
+	})
+
+	t.Run("add records in chunks", func(t *testing.T) {
+		bulkBox, err := repo.CreateBox(context.Background(), db.CreateBoxParams{
+			Name:       "Bulkbox",
+			Containers: []string{"hostnames"},
+		})
+		assert.Nil(err)
+
+		assert.Nil(repo.UpdateBoxQuota(context.Background(), db.UpdateBoxQuotaParams{
+			RecordsQuota: sql.NullInt64{Int64: 20000, Valid: true},
+			ID:           bulkBox.ID,
+		}))
+
+		var body strings.Builder
+		for i := 0; i < 12000; i++ {
+			fmt.Fprintf(&body, "%d.example.com\n", i)
+		}
+		body.WriteString("1.example.com\n11999.example.com\n")
+
+		req := httptest.NewRequest(http.MethodPost, "/api/box/"+bulkBox.ID.String()+"/hostnames", strings.NewReader(body.String()))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+
+		result := db.InsertResult{}
+		assert.Nil(json.Unmarshal(rec.Body.Bytes(), &result))
+		assert.Equal(int64(12002), result.Received)
+		assert.Equal(int64(12000), result.Inserted)
+		assert.Equal(int64(2), result.Duplicates)
+		assert.Equal(int64(12000), result.Usage.Count)
+
+		count, err := repo.CountRecordsByBox(context.Background(), bulkBox.ID)
+		assert.Nil(err)
+		assert.Equal(int64(12000), count)
+	})
+
+	t.Run("fold repeated records", func(t *testing.T) {
+		body := strings.Repeat("repeated.example.com\n", 3)
+		req := httptest.NewRequest(http.MethodPost, "/api/box/"+box.ID.String()+"/hostnames?anew=1", strings.NewReader(body))
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+		assert.Equal("repeated.example.com\n", rec.Body.String())
+		assert.NotEmpty(rec.Header().Get("X-Quota-Used"))
+
+		records, err := repo.ListRecordsByBoxFilter(context.Background(), db.ListRecordsByBoxFilterParams{
+			BoxID:     box.ID,
+			Container: "hostnames",
+			Column3:   []string{},
+			Data:      "repeated.example.com",
+		})
+		assert.Nil(err)
+
+		assert.Len(records, 1)
+		assert.Equal(int32(3), records[0].SeenCount)
+	})
+
+	t.Run("reject unknown normalizer", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/urls/_settings", strings.NewReader(`{"normalizers": ["foo"]}`))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusBadRequest, rec.Result().StatusCode)
+	})
+}
+
+func TestUpdateRecords(t *testing.T) {
+	assert := assert.New(t)
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(context.Background(), db.CreateBoxParams{
+		Name:       "Testbox",
+		Containers: []string{"hostnames"},
+	})
+	assert.Nil(err)
+
+	err = repo.CreateRecord(context.Background(), db.CreateRecordParams{
+		BoxID:     box.ID,
+		Data:      "foo",
+		Container: "hostnames",
+		Tags:      []string{"a", "b"},
+	})
+	assert.Nil(err)
+
+	update := func(body string) []string {
+		req := httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/hostnames", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusOK, rec.Result().StatusCode)
+
+		records, err := repo.ListRecordsByBoxFilter(context.Background(), db.ListRecordsByBoxFilterParams{
+			BoxID:     box.ID,
+			Container: "hostnames",
+			Column3:   []string{},
+			Data:      "foo",
+		})
+		assert.Nil(err)
+		assert.Len(records, 1)
+
+		return records[0].Tags
+	}
+
+	t.Run("add tags", func(t *testing.T) {
+		tags := update(`{"records": ["foo"], "tags": ["b", "c"], "action": "add"}`)
+		assert.Equal([]string{"a", "b", "c"}, tags)
+	})
+
+	t.Run("remove tags", func(t *testing.T) {
+		tags := update(`{"records": ["foo"], "tags": ["a"], "action": "remove"}`)
+		assert.Equal([]string{"b", "c"}, tags)
+	})
+
+	t.Run("replace tags", func(t *testing.T) {
+		tags := update(`{"records": ["foo"], "tags": ["x"]}`)
+		assert.Equal([]string{"x"}, tags)
+	})
+
+	t.Run("reject adding more than TAGS_MAX tags", func(t *testing.T) {
+		body := `{"records": ["foo"], "tags": ["a", "b", "c", "d", "e", "f", "g", "h", "i", "j"], "action": "add"}`
+		req := httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/hostnames", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(http.StatusNotAcceptable, rec.Code)
+
+		tags := update(`{"records": ["foo"], "tags": ["x"], "action": "add"}`)
+		assert.Equal([]string{"x"}, tags)
+	})
+}
+
+func TestBulkRecords(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "Testbox",
+		Containers: []string{"hostnames"},
+	})
+	assert.Nil(err)
+
+	records := map[string][]string{
+		"a.example.com": {"source:gau"},
+		"b.example.com": {"source:gau", "x"},
+		"c.example.com": {"source:crt"},
+	}
+	for data, tags := range records {
+		err = repo.CreateRecord(ctx, db.CreateRecordParams{
+			BoxID:     box.ID,
+			Data:      data,
+			Container: "hostnames",
+			Tags:      tags,
+		})
+		assert.Nil(err)
+	}
+
+	type Data struct {
+		Affected int64 `json:"affected"`
+		DryRun   bool  `json:"dry_run"`
+	}
+
+	bulk := func(body string, status int) Data {
+		req := httptest.NewRequest(http.MethodPut, "/api/box/"+box.ID.String()+"/hostnames/_bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(status, rec.Code)
+
+		d := Data{}
+		if status == http.StatusOK {
+			assert.Nil(json.Unmarshal(rec.Body.Bytes(), &d))
+		}
+		return d
+	}
+
+	count := func(term string) int64 {
+		query, err := search.Parse(term)
+		assert.Nil(err)
+
+		count, err := repo.CountRecordsBySearch(ctx, db.CountRecordsBySearchParams{
+			BoxID:     box.ID,
+			Container: "hostnames",
+			Query:     query,
+		})
+		assert.Nil(err)
+		return count
+	}
+
+	t.Run("dry run", func(t *testing.T) {
+		d := bulk(`{"term": "tag:source:gau", "action": "add", "tags": ["x"], "dry_run": true}`, http.StatusOK)
+		assert.True(d.DryRun)
+		assert.Equal(int64(1), d.Affected)
+		assert.Equal(int64(1), count("tag:x"))
+	})
+
+	t.Run("add tags", func(t *testing.T) {
+		d := bulk(`{"term": "tag:source:gau", "action": "add", "tags": ["x"]}`, http.StatusOK)
+		assert.Equal(int64(1), d.Affected)
+		assert.Equal(int64(2), count("tag:x"))
+	})
+
+	t.Run("remove tags", func(t *testing.T) {
+		d := bulk(`{"term": "example.com", "action": "remove", "tags": ["x"]}`, http.StatusOK)
+		assert.Equal(int64(2), d.Affected)
+		assert.Equal(int64(0), count("tag:x"))
+	})
+
+	t.Run("replace tags", func(t *testing.T) {
+		d := bulk(`{"term": "c.example.com", "action": "replace", "tags": ["y"]}`, http.StatusOK)
+		assert.Equal(int64(1), d.Affected)
+		assert.Equal(int64(1), count("tag:y -tag:source:crt"))
+
+		// records already carrying exactly these tags do not change
+		d = bulk(`{"term": "c.example.com", "action": "replace", "tags": ["y"]}`, http.StatusOK)
+		assert.Equal(int64(0), d.Affected)
+	})
+
+	t.Run("reject too many tags", func(t *testing.T) {
+		bulk(`{"term": "c.example.com", "action": "add", "tags": ["1", "2", "3", "4", "5", "6", "7", "8", "9"]}`, http.StatusOK)
+		bulk(`{"term": "c.example.com", "action": "add", "tags": ["10", "11"]}`, http.StatusNotAcceptable)
+		assert.Equal(int64(0), count("tag:10"))
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		d := bulk(`{"term": "tag:source:gau", "action": "delete"}`, http.StatusOK)
+		assert.Equal(int64(2), d.Affected)
+		assert.Equal(int64(1), count(""))
+	})
+
+	t.Run("reject invalid requests", func(t *testing.T) {
+		bulk(`{"action": "delete"}`, http.StatusBadRequest)
+		bulk(`{"term": "  ", "action": "delete"}`, http.StatusBadRequest)
+		bulk(`{"term": "(foo", "action": "delete"}`, http.StatusBadRequest)
+		bulk(`{"term": "foo", "action": "add"}`, http.StatusBadRequest)
+		bulk(`{"term": "foo", "action": "rename"}`, http.StatusBadRequest)
+	})
+}
+
+// BenchmarkListRecords searches a single container holding BENCH_RECORDS
+// records (1M by default): go test -run - -bench ListRecords ./web/
+func BenchmarkListRecords(b *testing.B) {
+	ctx := context.Background()
+
+	server, repo, dbc := MustSetupTest(b)
+	defer MustCloseTest(b, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "Benchbox",
+		Containers: []string{"hostnames"},
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	n := 1000000
+	if v, err := strconv.Atoi(os.Getenv("BENCH_RECORDS")); err == nil {
+		n = v
+	}
+
+	// seeding through the api would take longer than the benchmark itself
+	if _, err = dbc.Exec(ctx, `INSERT INTO records (box_id, container, data, tags)
+        SELECT $1, 'hostnames', 'host-' || i || '.example' || (i % 100) || '.com', ARRAY['source:' || (i % 10), 'batch:' || (i % 1000)]::varchar[]
+        FROM generate_series(1, $2::int) i`, box.ID, n); err != nil {
+		b.Fatal(err)
+	}
+
+	if _, err = dbc.Exec(ctx, "ANALYZE records"); err != nil {
+		b.Fatal(err)
+	}
+
+	// explain returns the plan of the records matching term, with sequential
+	// scans disabled so that any usable index is picked
+	explain := func(b *testing.B, term string) string {
+		query, err := search.Parse(term)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		where, args := query.Where(2)
+
+		tx, err := dbc.Begin(ctx)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer tx.Rollback(ctx)
+
+		if _, err := tx.Exec(ctx, "SET LOCAL enable_seqscan = off"); err != nil {
+			b.Fatal(err)
+		}
+
+		rows, err := tx.Query(ctx, "EXPLAIN SELECT count(*) FROM records WHERE box_id = $1 AND container = $2 AND "+where,
+			append([]interface{}{box.ID, "hostnames"}, args...)...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer rows.Close()
+
+		var plan strings.Builder
+		for rows.Next() {
+			var line string
+			if err := rows.Scan(&line); err != nil {
+				b.Fatal(err)
+			}
+			plan.WriteString(line + "\n")
+		}
+		if err := rows.Err(); err != nil {
+			b.Fatal(err)
+		}
+
+		return plan.String()
+	}
+
+	terms := []struct {
+		name  string
+		term  string
+		index string
+	}{
+		{"substring", "host-4242", "idx_records_data_trgm"},
+		{"suffix", "*.example42.com", "idx_records_data_trgm"},
+		{"tag", "tag:source:3", ""},
+		{"rare tag", "tag:batch:42", "idx_records_tags"},
+		{"substring and tag", "4242 tag:source:2", ""},
+		{"exclusion", "host-4242 -tag:source:2", ""},
+	}
+
+	for _, tt := range terms {
+		b.Run(tt.name, func(b *testing.B) {
+			// a search falling back to scanning all records of the box would
+			// only show in the timings otherwise
+			if tt.index != "" {
+				if plan := explain(b, tt.term); !strings.Contains(plan, tt.index) {
+					b.Fatalf("search %q does not use %s:\n%s", tt.term, tt.index, plan)
+				}
+			}
+
+			for i := 0; i < b.N; i++ {
+				req := httptest.NewRequest(http.MethodGet, "/api/box/"+box.ID.String()+"/hostnames?limit=100&term="+url.QueryEscape(tt.term), nil)
+				rec := httptest.NewRecorder()
+				server.ServeHTTP(rec, req)
+
+				if rec.Code != http.StatusOK {
+					b.Fatalf("unexpected status %v", rec.Code)
+				}
+			}
+		})
+	}
 }
 
 // TODO: CountRecords
-// TODO: UpdateRecords
 // TODO: DeleteRecords
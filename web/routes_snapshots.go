@@ -0,0 +1,238 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hntr/db"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// ListSnapshots lists the snapshots of a box, the latest first.
+func (s *Server) ListSnapshots(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	snapshots, err := s.repo.ListSnapshots(ctx, id)
+	if err != nil {
+		log.Printf("listing snapshots failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"snapshots": snapshots,
+	})
+}
+
+// CreateSnapshot takes a named snapshot of the records of a box.
+func (s *Server) CreateSnapshot(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	box, err := s.repo.GetBox(ctx, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	type CreateSnapshot struct {
+		Name string `json:"name" validate:"required,max=50"`
+	}
+
+	create := new(CreateSnapshot)
+	if err = c.Bind(create); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid data",
+		})
+	}
+
+	if err = c.Validate(create); err != nil {
+		errors := err.(validator.ValidationErrors)
+		firstError := errors[0]
+
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("%s: %s", firstError.Field(), validationErrorMsg(firstError)),
+		})
+	}
+
+	count, err := s.repo.CountSnapshots(ctx, db.CountSnapshotsParams{
+		BoxID: box.ID,
+		Kind:  db.SnapshotManual,
+	})
+	if err != nil {
+		log.Printf("counting snapshots failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if count >= SNAPSHOTS_MAX {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("too many snapshots, please delete some first. SNAPSHOTS_MAX=%v", SNAPSHOTS_MAX),
+		})
+	}
+
+	snapshot, records, err := db.TakeSnapshot(ctx, s.dbPool, db.CreateSnapshotParams{
+		BoxID: box.ID,
+		Name:  create.Name,
+		Kind:  db.SnapshotManual,
+	}, 0, box.Quotas(s.quotas).Records)
+	if err != nil {
+		if errors.Is(err, db.ErrSnapshotsQuota) {
+			return c.JSON(http.StatusBadRequest, map[string]string{
+				"error": fmt.Sprintf("snapshots would keep too many records, please delete some first (%v)", err),
+			})
+		}
+
+		log.Printf("taking snapshot failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"snapshot": snapshot,
+		"records":  records,
+	})
+}
+
+// DeleteSnapshot removes a snapshot of a box.
+func (s *Server) DeleteSnapshot(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	snapshotID, err := uuid.Parse(c.Param("snapshotid"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	deleted, err := s.repo.DeleteSnapshot(ctx, db.DeleteSnapshotParams{
+		ID:    snapshotID,
+		BoxID: id,
+	})
+	if err != nil {
+		log.Printf("deleting snapshot failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if deleted == 0 {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	return c.JSON(http.StatusOK, nil)
+}
+
+// DiffSnapshot lists the records added, removed or retagged per container
+// since a snapshot was taken. The changes are compared to the current
+// records, or to the snapshot given by the to query param. At most limit
+// changes are listed per request, further ones start at the next offset.
+func (s *Server) DiffSnapshot(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	from, err := s.getSnapshot(ctx, id, c.Param("snapshotid"))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting snapshot failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	limit, _ := strconv.Atoi(c.QueryParam("limit"))
+	offset, _ := strconv.Atoi(c.QueryParam("offset"))
+
+	if limit < 1 || limit > LIMIT_MAX {
+		limit = LIMIT_MAX
+	}
+
+	if offset < 0 {
+		offset = 0
+	}
+
+	params := db.DiffSnapshotParams{
+		FromID: from.ID,
+		BoxID:  id,
+		Limit:  int32(limit),
+		Offset: int32(offset),
+	}
+
+	var to *db.Snapshot
+	if c.QueryParam("to") != "" {
+		snapshot, err := s.getSnapshot(ctx, id, c.QueryParam("to"))
+		if err != nil {
+			if err == pgx.ErrNoRows {
+				return c.JSON(http.StatusNotFound, nil)
+			}
+
+			log.Printf("getting snapshot failed: %v", err)
+			return c.JSON(http.StatusInternalServerError, nil)
+		}
+
+		to = &snapshot
+		params.ToID = uuid.NullUUID{UUID: snapshot.ID, Valid: true}
+	}
+
+	changes, err := s.repo.DiffSnapshot(ctx, params)
+	if err != nil {
+		log.Printf("diffing snapshot failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	// a full page may be followed by more changes
+	var next interface{}
+	if len(changes) == limit {
+		next = offset + limit
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"from":       from,
+		"to":         to,
+		"containers": db.GroupDiff(changes),
+		"next":       next,
+	})
+}
+
+// getSnapshot returns the snapshot with the given id, if it belongs to the
+// box. Otherwise pgx.ErrNoRows is returned.
+func (s *Server) getSnapshot(ctx context.Context, boxID uuid.UUID, snapshotID string) (db.Snapshot, error) {
+	id, err := uuid.Parse(snapshotID)
+	if err != nil {
+		return db.Snapshot{}, pgx.ErrNoRows
+	}
+
+	snapshot, err := s.repo.GetSnapshot(ctx, id)
+	if err != nil {
+		return snapshot, err
+	}
+
+	if snapshot.BoxID != boxID {
+		return db.Snapshot{}, pgx.ErrNoRows
+	}
+
+	return snapshot, nil
+}
@@ -0,0 +1,140 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"hntr/db"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshots(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "Testbox",
+		Containers: []string{"hostnames", "urls"},
+	})
+	assert.Nil(err)
+
+	records := []db.CreateRecordParams{
+		{Data: "a.example.com", Container: "hostnames", Tags: []string{"x", "y"}},
+		{Data: "b.example.com", Container: "hostnames"},
+		{Data: "https://a.example.com", Container: "urls"},
+	}
+	for _, record := range records {
+		record.BoxID = box.ID
+		assert.Nil(repo.CreateRecord(ctx, record))
+	}
+
+	type Data struct {
+		Snapshot   db.Snapshot                  `json:"snapshot"`
+		Records    int64                        `json:"records"`
+		Snapshots  []db.ListSnapshotsRow        `json:"snapshots"`
+		Containers map[string]*db.ContainerDiff `json:"containers"`
+		Next       *int                         `json:"next"`
+	}
+
+	request := func(method, url, body string, status int) Data {
+		req := httptest.NewRequest(method, "/api/box/"+box.ID.String()+url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(status, rec.Code)
+
+		d := Data{}
+		if status == http.StatusOK {
+			assert.Nil(json.Unmarshal(rec.Body.Bytes(), &d))
+		}
+		return d
+	}
+
+	first := request(http.MethodPost, "/_snapshots", `{"name": "review"}`, http.StatusOK)
+	assert.Equal("review", first.Snapshot.Name)
+	assert.Equal(db.SnapshotManual, first.Snapshot.Kind)
+	assert.Equal(int64(3), first.Records)
+
+	// change the records after the snapshot
+	assert.Nil(repo.CreateRecord(ctx, db.CreateRecordParams{Data: "c.example.com", Container: "hostnames", BoxID: box.ID}))
+	assert.Nil(repo.DeleteRecords(ctx, db.DeleteRecordsParams{BoxID: box.ID, Container: "urls", Column3: []string{"https://a.example.com"}}))
+	assert.Nil(repo.UpdateRecordTags(ctx, db.UpdateRecordTagsParams{Tags: []string{"y", "x"}, BoxID: box.ID, Container: "hostnames", Column4: []string{"a.example.com"}}))
+	assert.Nil(repo.UpdateRecordTags(ctx, db.UpdateRecordTagsParams{Tags: []string{"z"}, BoxID: box.ID, Container: "hostnames", Column4: []string{"b.example.com"}}))
+
+	t.Run("diff against current records", func(t *testing.T) {
+		d := request(http.MethodGet, "/_snapshots/"+first.Snapshot.ID.String()+"/_diff", "", http.StatusOK)
+
+		assert.Equal(map[string]*db.ContainerDiff{
+			"hostnames": {
+				Added:       []db.DiffEntry{{Data: "c.example.com"}},
+				Removed:     []db.DiffEntry{},
+				TagsChanged: []db.DiffEntry{{Data: "b.example.com", Tags: []string{"z"}}},
+			},
+			"urls": {
+				Added:       []db.DiffEntry{},
+				Removed:     []db.DiffEntry{{Data: "https://a.example.com"}},
+				TagsChanged: []db.DiffEntry{},
+			},
+		}, d.Containers)
+	})
+
+	t.Run("diff in pages", func(t *testing.T) {
+		d := request(http.MethodGet, "/_snapshots/"+first.Snapshot.ID.String()+"/_diff?limit=2", "", http.StatusOK)
+		assert.Len(d.Containers, 1)
+		assert.Len(d.Containers["hostnames"].Added, 1)
+		assert.Len(d.Containers["hostnames"].TagsChanged, 1)
+		if assert.NotNil(d.Next) {
+			assert.Equal(2, *d.Next)
+		}
+
+		d = request(http.MethodGet, "/_snapshots/"+first.Snapshot.ID.String()+"/_diff?limit=2&offset=2", "", http.StatusOK)
+		assert.Len(d.Containers, 1)
+		assert.Len(d.Containers["urls"].Removed, 1)
+		assert.Nil(d.Next)
+	})
+
+	t.Run("diff two snapshots", func(t *testing.T) {
+		second := request(http.MethodPost, "/_snapshots", `{"name": "later"}`, http.StatusOK)
+
+		d := request(http.MethodGet, "/_snapshots/"+second.Snapshot.ID.String()+"/_diff?to="+first.Snapshot.ID.String(), "", http.StatusOK)
+		assert.Len(d.Containers, 2)
+		assert.Equal([]db.DiffEntry{{Data: "c.example.com"}}, d.Containers["hostnames"].Removed)
+		assert.Equal([]db.DiffEntry{{Data: "https://a.example.com"}}, d.Containers["urls"].Added)
+
+		d = request(http.MethodGet, "/_snapshots", "", http.StatusOK)
+		assert.Len(d.Snapshots, 2)
+		assert.Equal("later", d.Snapshots[0].Name)
+		assert.Equal(int64(3), d.Snapshots[0].Records)
+
+		request(http.MethodDelete, "/_snapshots/"+second.Snapshot.ID.String(), "", http.StatusOK)
+		request(http.MethodDelete, "/_snapshots/"+second.Snapshot.ID.String(), "", http.StatusNotFound)
+	})
+
+	t.Run("reject invalid requests", func(t *testing.T) {
+		request(http.MethodPost, "/_snapshots", `{}`, http.StatusBadRequest)
+		request(http.MethodGet, "/_snapshots/foo/_diff", "", http.StatusNotFound)
+		request(http.MethodGet, "/_snapshots/"+first.Snapshot.ID.String()+"/_diff?to=foo", "", http.StatusNotFound)
+	})
+
+	t.Run("reject snapshots over the quota", func(t *testing.T) {
+		// the first snapshot and the current records make 6 records
+		assert.Nil(repo.UpdateBoxQuota(ctx, db.UpdateBoxQuotaParams{
+			RecordsQuota: sql.NullInt64{Int64: 5, Valid: true},
+			ID:           box.ID,
+		}))
+
+		request(http.MethodPost, "/_snapshots", `{"name": "full"}`, http.StatusBadRequest)
+
+		d := request(http.MethodGet, "/_snapshots", "", http.StatusOK)
+		assert.Len(d.Snapshots, 1)
+	})
+}
@@ -0,0 +1,273 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"hntr/db"
+	"hntr/search"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/labstack/echo/v4"
+)
+
+// ListTags lists the distinct tags of every container in a box together with
+// the number of records carrying them.
+func (s *Server) ListTags(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	tags, err := s.repo.ListTagCounts(ctx, id)
+	if err != nil {
+		log.Printf("listing tags failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"tags": tags,
+	})
+}
+
+// RenameTag renames a tag on all records of a box, in the source tags,
+// source terms and destination tags of its automations and in the retention
+// rules of its containers. Records already carrying the new tag
+// just lose the old one.
+func (s *Server) RenameTag(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	box, err := s.repo.GetBox(ctx, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	type RenameTag struct {
+		Tag    string `json:"tag" validate:"required,max=50"`
+		NewTag string `json:"new_tag" validate:"required,max=50,nefield=Tag"`
+	}
+
+	rename := new(RenameTag)
+	if err = c.Bind(rename); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "invalid data",
+		})
+	}
+
+	if err = c.Validate(rename); err != nil {
+		errors := err.(validator.ValidationErrors)
+
+		firstError := errors[0]
+
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": fmt.Sprintf("%s: %s", firstError.Field(), validationErrorMsg(firstError)),
+		})
+	}
+
+	tx, err := s.dbPool.Begin(ctx)
+	if err != nil {
+		log.Printf("starting transaction failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	repo := s.repo.WithTx(tx)
+
+	affected, err := repo.RenameRecordTag(ctx, db.RenameRecordTagParams{
+		NewTag: rename.NewTag,
+		Tag:    rename.Tag,
+		BoxID:  box.ID,
+	})
+	if err != nil {
+		log.Printf("renaming record tag failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := repo.RenameAutomationTag(ctx, db.RenameAutomationTagParams{
+		NewTag: rename.NewTag,
+		Tag:    rename.Tag,
+		BoxID:  box.ID,
+	}); err != nil {
+		log.Printf("renaming automation tag failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := renameSourceTermTag(ctx, repo, box.ID, rename.Tag, rename.NewTag); err != nil {
+		log.Printf("renaming source term tag failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := updateRetentionTag(ctx, repo, box, rename.Tag, rename.NewTag); err != nil {
+		log.Printf("renaming retention tag failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("committing tag rename failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"affected": affected,
+	})
+}
+
+// DeleteTag removes a tag from all records of a box and from the destination
+// tags of its automations. Retention rules of the tag are removed as well. A
+// tag selecting the source records of automations is not deleted, as they
+// would run on all records of their source container otherwise.
+func (s *Server) DeleteTag(c echo.Context) error {
+	ctx := context.Background()
+
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, nil)
+	}
+
+	box, err := s.repo.GetBox(ctx, id)
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return c.JSON(http.StatusNotFound, nil)
+		}
+
+		log.Printf("getting box failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	tag := c.QueryParam("tag")
+	if tag == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{
+			"error": "Tag: This field is required",
+		})
+	}
+
+	automations, err := s.repo.ListAutomations(ctx, box.ID)
+	if err != nil {
+		log.Printf("listing automations failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if names := sourceTagAutomations(automations, tag); len(names) > 0 {
+		return c.JSON(http.StatusConflict, map[string]interface{}{
+			"error":       fmt.Sprintf("tag %s selects the source records of automations, please change them first: %s", tag, strings.Join(names, ", ")),
+			"automations": names,
+		})
+	}
+
+	tx, err := s.dbPool.Begin(ctx)
+	if err != nil {
+		log.Printf("starting transaction failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+	defer tx.Rollback(ctx)
+
+	repo := s.repo.WithTx(tx)
+
+	affected, err := repo.DeleteRecordTag(ctx, db.DeleteRecordTagParams{
+		BoxID:   box.ID,
+		Column2: tag,
+	})
+	if err != nil {
+		log.Printf("deleting record tag failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := repo.DeleteAutomationTag(ctx, db.DeleteAutomationTagParams{
+		BoxID:   box.ID,
+		Column2: tag,
+	}); err != nil {
+		log.Printf("deleting automation tag failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := updateRetentionTag(ctx, repo, box, tag, ""); err != nil {
+		log.Printf("deleting retention tag failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		log.Printf("committing tag deletion failed: %v", err)
+		return c.JSON(http.StatusInternalServerError, nil)
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"affected": affected,
+	})
+}
+
+// updateRetentionTag renames tag in the retention rules of the containers of
+// box, or removes its rules if newTag is empty.
+func updateRetentionTag(ctx context.Context, repo *db.Queries, box db.Box, tag, newTag string) error {
+	settings, changed := box.RenameRetentionTag(tag, newTag)
+	if !changed {
+		return nil
+	}
+
+	encoded, err := db.EncodeSettings(settings)
+	if err != nil {
+		return err
+	}
+
+	return repo.UpdateBoxContainerSettings(ctx, db.UpdateBoxContainerSettingsParams{
+		ContainerSettings: encoded,
+		ID:                box.ID,
+	})
+}
+
+// sourceTagAutomations returns the names of the automations which select
+// their source records by tag, either by their source tags or source term.
+func sourceTagAutomations(automations []db.Automation, tag string) []string {
+	names := make([]string, 0)
+
+	for _, automation := range automations {
+		// source terms are validated when they are stored
+		tags, _ := search.Tags(automation.SourceTerm)
+
+		if inStringSlice(tag, automation.SourceTags) || inStringSlice(tag, tags) {
+			names = append(names, automation.Name)
+		}
+	}
+
+	return names
+}
+
+// renameSourceTermTag renames tag in the source terms of the automations of a
+// box.
+func renameSourceTermTag(ctx context.Context, repo *db.Queries, boxID uuid.UUID, tag, newTag string) error {
+	automations, err := repo.ListAutomations(ctx, boxID)
+	if err != nil {
+		return err
+	}
+
+	for _, automation := range automations {
+		term, changed, err := search.RenameTag(automation.SourceTerm, tag, newTag)
+		if err != nil || !changed {
+			continue
+		}
+
+		if err := repo.UpdateAutomationSourceTerm(ctx, db.UpdateAutomationSourceTermParams{
+			SourceTerm: term,
+			ID:         automation.ID,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
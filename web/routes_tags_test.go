@@ -0,0 +1,128 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"hntr/db"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTags(t *testing.T) {
+	assert := assert.New(t)
+	ctx := context.Background()
+
+	server, repo, dbc := MustSetupTest(t)
+	defer MustCloseTest(t, dbc)
+
+	box, err := repo.CreateBox(ctx, db.CreateBoxParams{
+		Name:       "Testbox",
+		Containers: []string{"hostnames", "urls"},
+	})
+	assert.Nil(err)
+
+	records := []db.CreateRecordParams{
+		{Data: "a.example.com", Container: "hostnames", Tags: []string{"src:gau", "x"}},
+		{Data: "b.example.com", Container: "hostnames", Tags: []string{"src:gau", "source:gau"}},
+		{Data: "https://a.example.com", Container: "urls", Tags: []string{"src:gau"}},
+	}
+	for _, record := range records {
+		record.BoxID = box.ID
+		assert.Nil(repo.CreateRecord(ctx, record))
+	}
+
+	automation, err := repo.CreateAutomation(ctx, db.CreateAutomationParams{
+		BoxID:                box.ID,
+		Name:                 "httpx",
+		SourceContainer:      "hostnames",
+		SourceTags:           []string{"src:gau"},
+		SourceTerm:           "-tag:oos",
+		DestinationContainer: "urls",
+		DestinationTags:      []string{"x"},
+	})
+	assert.Nil(err)
+
+	type Data struct {
+		Tags     []db.ListTagCountsRow `json:"tags"`
+		Affected int64                 `json:"affected"`
+	}
+
+	request := func(method, url, body string, status int) Data {
+		req := httptest.NewRequest(method, "/api/box/"+box.ID.String()+url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(status, rec.Code)
+
+		d := Data{}
+		if status == http.StatusOK {
+			assert.Nil(json.Unmarshal(rec.Body.Bytes(), &d))
+		}
+		return d
+	}
+
+	t.Run("list tags", func(t *testing.T) {
+		d := request(http.MethodGet, "/_tags", "", http.StatusOK)
+		assert.Equal([]db.ListTagCountsRow{
+			{Container: "hostnames", Tag: "source:gau", Count: 1},
+			{Container: "hostnames", Tag: "src:gau", Count: 2},
+			{Container: "hostnames", Tag: "x", Count: 1},
+			{Container: "urls", Tag: "src:gau", Count: 1},
+		}, d.Tags)
+	})
+
+	t.Run("rename tag", func(t *testing.T) {
+		d := request(http.MethodPut, "/_tags", `{"tag": "src:gau", "new_tag": "source:gau"}`, http.StatusOK)
+		assert.Equal(int64(3), d.Affected)
+
+		d = request(http.MethodGet, "/_tags", "", http.StatusOK)
+		assert.Equal([]db.ListTagCountsRow{
+			{Container: "hostnames", Tag: "source:gau", Count: 2},
+			{Container: "hostnames", Tag: "x", Count: 1},
+			{Container: "urls", Tag: "source:gau", Count: 1},
+		}, d.Tags)
+
+		a, err := repo.GetAutomation(ctx, automation.ID)
+		assert.Nil(err)
+		assert.Equal([]string{"source:gau"}, a.SourceTags)
+	})
+
+	t.Run("rename tag in source terms", func(t *testing.T) {
+		d := request(http.MethodPut, "/_tags", `{"tag": "oos", "new_tag": "out of scope"}`, http.StatusOK)
+		assert.Equal(int64(0), d.Affected)
+
+		a, err := repo.GetAutomation(ctx, automation.ID)
+		assert.Nil(err)
+		assert.Equal(`-tag:"out of scope"`, a.SourceTerm)
+	})
+
+	t.Run("keep tags selecting automation sources", func(t *testing.T) {
+		request(http.MethodDelete, "/_tags?tag=source:gau", "", http.StatusConflict)
+		request(http.MethodDelete, "/_tags?tag="+url.QueryEscape("out of scope"), "", http.StatusConflict)
+
+		a, err := repo.GetAutomation(ctx, automation.ID)
+		assert.Nil(err)
+		assert.Equal([]string{"source:gau"}, a.SourceTags)
+	})
+
+	t.Run("delete tag", func(t *testing.T) {
+		d := request(http.MethodDelete, "/_tags?tag=x", "", http.StatusOK)
+		assert.Equal(int64(1), d.Affected)
+
+		a, err := repo.GetAutomation(ctx, automation.ID)
+		assert.Nil(err)
+		assert.Equal([]string{}, a.DestinationTags)
+	})
+
+	t.Run("reject invalid requests", func(t *testing.T) {
+		request(http.MethodPut, "/_tags", `{"tag": "a", "new_tag": "a"}`, http.StatusBadRequest)
+		request(http.MethodPut, "/_tags", `{"tag": "a"}`, http.StatusBadRequest)
+		request(http.MethodDelete, "/_tags", "", http.StatusBadRequest)
+	})
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"hntr/db"
 	"hntr/frontend"
+	"hntr/recordtype"
 	"io/fs"
 	"net"
 	"net/http"
@@ -23,7 +24,10 @@ var ShutdownTimeout = 2 * time.Second
 
 const LIMIT_MAX = 50000
 const LIMIT_RECORDS = 100000
-const TAGS_MAX = 10
+const TAGS_MAX = db.TagsMax
+const ATTRIBUTES_MAX = 20
+const SNAPSHOTS_MAX = 20
+const SNAPSHOTS_AUTOMATION_MAX = 5
 
 type Server struct {
 	server *echo.Echo
@@ -33,7 +37,8 @@ type Server struct {
 	dbPool *pgxpool.Pool
 	queue  *gue.Client
 
-	recordsLimit int
+	// quotas apply to boxes which have none of their own
+	quotas db.Quotas
 }
 
 type CustomValidator struct {
@@ -59,22 +64,31 @@ func validationErrorMsg(fe validator.FieldError) string {
 
 	case "max":
 		return "Invalid maximum length"
+	case "oneof", "nefield":
+		return "Invalid value"
+	case "recordtype":
+		return "Invalid record type"
 	}
 	return fe.Error() // default error
 }
 
-func NewServer(addr string, recordsLimit int, repo *db.Queries, dbPool *pgxpool.Pool, gc *gue.Client) *Server {
+func NewServer(addr string, quotas db.Quotas, repo *db.Queries, dbPool *pgxpool.Pool, gc *gue.Client) *Server {
 	debugMode := os.Getenv("DEBUG") != ""
 
 	server := &Server{
-		addr:         addr,
-		queue:        gc,
-		dbPool:       dbPool,
-		recordsLimit: recordsLimit,
+		addr:   addr,
+		queue:  gc,
+		dbPool: dbPool,
+		quotas: quotas,
 	}
 
 	e := echo.New()
-	e.Validator = &CustomValidator{validator: validator.New()}
+	v := validator.New()
+	_ = v.RegisterValidation("recordtype", func(fl validator.FieldLevel) bool {
+		return recordtype.Known(fl.Field().String())
+	})
+
+	e.Validator = &CustomValidator{validator: v}
 	e.HideBanner = true
 
 	// Middleware
@@ -89,14 +103,39 @@ func NewServer(addr string, recordsLimit int, repo *db.Queries, dbPool *pgxpool.
 	// boxes
 	e.GET("/api/box/:id", server.GetBox)
 	e.POST("/api/box/create", server.CreateBox)
+	e.POST("/api/box/restore", server.RestoreBox)
+	e.GET("/api/box/:id/_backup", server.BackupBox)
 	e.PUT("/api/box/:id", server.UpdateBox)
 	e.DELETE("/api/box/:id", server.DeleteBox)
+	e.PUT("/api/box/:id/_scope", server.UpdateScope)
+	e.PUT("/api/box/:id/:container/_settings", server.UpdateContainerSettings)
+
+	// containers
+	e.PUT("/api/box/:id/:container/_rename", server.RenameContainer)
+	e.DELETE("/api/box/:id/:container", server.DeleteContainer)
+
+	// tags
+	e.GET("/api/box/:id/_tags", server.ListTags)
+	e.PUT("/api/box/:id/_tags", server.RenameTag)
+	e.DELETE("/api/box/:id/_tags", server.DeleteTag)
+
+	// snapshots
+	e.GET("/api/box/:id/_snapshots", server.ListSnapshots)
+	e.POST("/api/box/:id/_snapshots", server.CreateSnapshot)
+	e.DELETE("/api/box/:id/_snapshots/:snapshotid", server.DeleteSnapshot)
+	e.GET("/api/box/:id/_snapshots/:snapshotid/_diff", server.DiffSnapshot)
 
 	// records
 	e.GET("/api/box/:id/_count", server.CountRecords)
+	e.GET("/api/box/:id/:container/_count", server.CountFilteredRecords)
+	e.GET("/api/box/:id/:container/_export", server.ExportRecords)
+	e.GET("/api/box/:id/:container/_children", server.ListRecordChildren)
+	e.GET("/api/box/:id/:container/_parents", server.ListRecordParents)
+	e.GET("/api/box/:id/:container/_domains", server.ListDomains)
 	e.GET("/api/box/:id/:container", server.ListRecords)
 	e.POST("/api/box/:id/:container", server.AddRecords)
 	e.PUT("/api/box/:id/:container/_deleterecords", server.DeleteRecords)
+	e.PUT("/api/box/:id/:container/_bulk", server.BulkRecords)
 	e.PUT("/api/box/:id/:container", server.UpdateRecords)
 
 	// automations
@@ -105,6 +144,7 @@ func NewServer(addr string, recordsLimit int, repo *db.Queries, dbPool *pgxpool.
 	e.POST("/api/box/:id/_clear", server.ClearAutomationEvents)
 	e.GET("/api/box/:id/_dequeue", server.DequeueJobs)
 	e.POST("/api/box/:id/_results/:jobid", server.UpdateAutomationEvent)
+	e.GET("/api/box/:id/_results/:jobid", server.ListAutomationEventRecords)
 	e.POST("/api/box/:id/automations", server.AddAutomation)
 	e.GET("/api/automations/:id/events", server.ListAutomationEvents)
 	e.POST("/api/automations/:id/start", server.StartAutomation)
@@ -68,11 +68,12 @@ func MustSetupTest(tb testing.TB) (*echo.Echo, *db.Queries, *pgxpool.Pool) {
 	defer dbc.Close()
 
 	// setup queue
-	gc, shutdownQueue := jobs.Init(os.Getenv("POSTGRES_TEST_URL"), repo)
+	quotas := db.Quotas{Records: 1000, Events: 2000}
+	gc, shutdownQueue := jobs.Init(os.Getenv("POSTGRES_TEST_URL"), repo, quotas)
 	defer shutdownQueue()
 
 	// setup web server
-	server := NewServer(":0", 1000, repo, repoDbc, gc).Server()
+	server := NewServer(":0", quotas, repo, repoDbc, gc).Server()
 
 	return server, repo, repoDbc
 }